@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"github.com/sambaiz/cdkbot/tasks/operation/server"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+const usage = `Usage: cdkbot serve [options]
+
+Receive webhooks over HTTP at /webhook and run them without Lambda, SQS and ECS.
+
+Options:
+`
+
+func main() {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":8080", "address to listen on")
+	queueFile := fs.String("queue-file", "cdkbot-queue.json", "file to persist the queue over restarts")
+	concurrency := fs.Int("concurrency", 2, "number of jobs run concurrently. Jobs of the same repository and base branch are run one by one")
+	platform := fs.String("platform", os.Getenv("PLATFORM"), "github, gitlab, bitbucket or gitea (default $PLATFORM)")
+	if len(os.Args) < 2 || os.Args[1] != "serve" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(os.Args[2:])
+
+	logger := logger.New()
+	if *platform == "" {
+		*platform = "github"
+	}
+	q, err := queue.NewFileQueue(*queueFile)
+	if err != nil {
+		logger.Error("load queue error", zap.Error(err))
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("start serving", zap.String("addr", *addr), zap.String("platform", *platform))
+	if err := server.New(*platform, q, *concurrency, logger).Run(ctx, *addr); err != nil {
+		logger.Error("serve error", zap.Error(err))
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,11 +13,27 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	operationHandler "github.com/sambaiz/cdkbot/tasks/operation/handler"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
 )
 
 type response events.APIGatewayProxyResponse
 
-func handler(event events.APIGatewayProxyRequest) (response, error) {
+func handler(ctx context.Context, event events.APIGatewayProxyRequest) (response, error) {
+	groupID, err := operationHandler.MessageGroupID(ctx, os.Getenv("PLATFORM"), event)
+	if err != nil {
+		fmt.Println(err.Error())
+		return response{
+			StatusCode: http.StatusBadRequest,
+		}, nil
+	}
+	if groupID == "" {
+		// nothing is to do
+		return response{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
 	payload, err := json.Marshal(event)
 	if err != nil {
 		return response{
@@ -25,12 +42,10 @@ func handler(event events.APIGatewayProxyRequest) (response, error) {
 	}
 
 	sess := session.New()
-	sqsSvc := sqs.New(sess)
-	if _, err := sqsSvc.SendMessage(&sqs.SendMessageInput{
-		MessageBody:    aws.String(string(payload)),
-		QueueUrl:       aws.String(os.Getenv("OPERATION_QUEUE_URL")),
-		MessageGroupId: aws.String("group"),
-	}); err != nil {
+	if err := queue.NewSQS(
+		sqs.New(sess),
+		os.Getenv("OPERATION_QUEUE_URL"),
+	).Send(ctx, groupID, string(payload)); err != nil {
 		fmt.Println(err.Error())
 		return response{
 			StatusCode: http.StatusInternalServerError,
@@ -1,25 +1,50 @@
 package cdk
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Clienter is interface of CDK client
 type Clienter interface {
-	Setup(repoPath string) error
-	List(repoPath string, contexts map[string]string) ([]string, error)
-	Diff(repoPath string, stacks []string, contexts map[string]string) (string, bool, error)
-	Deploy(repoPath string, stacks []string, contexts map[string]string) (string, error)
+	Setup(ctx context.Context, repoPath string) error
+	List(ctx context.Context, repoPath string, contexts map[string]string) ([]string, error)
+	Diff(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, bool, error)
+	Deploy(ctx context.Context, repoPath string, stacks []string, flags []string, contexts map[string]string) (string, error)
+	Synth(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (map[string]string, error)
+	Dependencies(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) ([]string, error)
+	Destroy(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, error)
 }
 
 // Client is CDK client
 type Client struct{}
 
+// How long to wait for cdk to stop after it is interrupted by canceling ctx
+const stopTimeout = time.Minute
+
+// npmCommand returns the command which is interrupted when ctx is done
+// so that cdk stops gracefully, and is killed if it doesn't stop in stopTimeout
+func npmCommand(ctx context.Context, repoPath string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "npm", args...)
+	cmd.Dir = repoPath
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = stopTimeout
+	return cmd
+}
+
 // Setup env to run cdk commands
-func (*Client) Setup(repoPath string) error {
+func (*Client) Setup(ctx context.Context, repoPath string) error {
 	if err := os.Setenv("NPM_CONFIG_USERCONFIG", "/opt/nodejs/.npmrc"); err != nil {
 		return err
 	}
@@ -28,8 +53,7 @@ func (*Client) Setup(repoPath string) error {
 	if err := os.Setenv("HOME", "/tmp"); err != nil {
 		return err
 	}
-	cmd := exec.Command("npm", "install")
-	cmd.Dir = repoPath
+	cmd := npmCommand(ctx, repoPath, "install")
 	out, err := cmd.CombinedOutput()
 	if err != nil || cmd.ProcessState.ExitCode() != 0 {
 		return fmt.Errorf("npm install failed: %s %v", string(out), err)
@@ -38,23 +62,28 @@ func (*Client) Setup(repoPath string) error {
 }
 
 // List stack
-func (*Client) List(repoPath string, contexts map[string]string) ([]string, error) {
+func (*Client) List(ctx context.Context, repoPath string, contexts map[string]string) ([]string, error) {
 	args := []string{"run", "cdk", "--", "list"}
 	for k, v := range contexts {
 		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
 	}
-	cmd := exec.Command("npm", args...)
-	cmd.Dir = repoPath
+	cmd := npmCommand(ctx, repoPath, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil || cmd.ProcessState.ExitCode() != 0 {
 		return nil, fmt.Errorf("cdk list failed: %s %v", string(out), err)
 	}
-	lists := strings.Split(strings.Trim(string(out), "\n"), "\n")[3:]
+	lists := []string{}
+	for _, line := range strings.Split(strings.Trim(string(out), "\n"), "\n")[3:] {
+		// stacks in stages are listed with the display name such as "Stage/Stack (Stage-Stack)"
+		if fields := strings.Fields(line); len(fields) != 0 {
+			lists = append(lists, fields[0])
+		}
+	}
 	return lists, nil
 }
 
 // Diff stack and returns (diff, hasDiff, error)
-func (*Client) Diff(repoPath string, stacks []string, contexts map[string]string) (string, bool, error) {
+func (*Client) Diff(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, bool, error) {
 	args := []string{"run", "cdk", "--", "diff"}
 	for _, stack := range stacks {
 		args = append(args, stack)
@@ -62,8 +91,7 @@ func (*Client) Diff(repoPath string, stacks []string, contexts map[string]string
 	for k, v := range contexts {
 		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
 	}
-	cmd := exec.Command("npm", args...)
-	cmd.Dir = repoPath
+	cmd := npmCommand(ctx, repoPath, args...)
 	out, err := cmd.CombinedOutput()
 	// If the error code is 0, there is no diff, if it is 1, there is diff, otherwise it is an error
 	if cmd.ProcessState.ExitCode() != 0 && cmd.ProcessState.ExitCode() != 1 {
@@ -78,18 +106,18 @@ func (*Client) Diff(repoPath string, stacks []string, contexts map[string]string
 	return strings.Trim(strings.Join(lines, "\n"), "\n"), cmd.ProcessState.ExitCode() != 0, nil
 }
 
-// Deploy stack
-func (*Client) Deploy(repoPath string, stacks []string, contexts map[string]string) (string, error) {
+// Deploy stack with flags such as "--hotswap"
+func (*Client) Deploy(ctx context.Context, repoPath string, stacks []string, flags []string, contexts map[string]string) (string, error) {
 	args := []string{"run", "cdk", "--", "deploy"}
 	for _, stack := range stacks {
 		args = append(args, stack)
 	}
+	args = append(args, flags...)
 	args = append(args, []string{"--require-approval", "never"}...)
 	for k, v := range contexts {
 		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
 	}
-	cmd := exec.Command("npm", args...)
-	cmd.Dir = repoPath
+	cmd := npmCommand(ctx, repoPath, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil || cmd.ProcessState.ExitCode() != 0 {
 		return "failed!", fmt.Errorf("cdk deploy failed: %s %v", string(out), err)
@@ -102,3 +130,219 @@ func (*Client) Deploy(repoPath string, stacks []string, contexts map[string]stri
 	}
 	return strings.Trim(strings.Join(lines, "\n"), "\n"), err
 }
+
+// Destroy stack
+func (*Client) Destroy(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, error) {
+	args := []string{"run", "cdk", "--", "destroy"}
+	for _, stack := range stacks {
+		args = append(args, stack)
+	}
+	args = append(args, "--force")
+	for k, v := range contexts {
+		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd := npmCommand(ctx, repoPath, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil || cmd.ProcessState.ExitCode() != 0 {
+		return "failed!", fmt.Errorf("cdk destroy failed: %s %v", string(out), err)
+	}
+	lines := []string{}
+	for _, line := range strings.Split(strings.Trim(string(out), "\n"), "\n")[3:] {
+		if !strings.HasPrefix(line, "npm ERR!") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n"), nil
+}
+
+// Directory under repoPath where cdk synth writes templates
+const synthOutputDir = "cdkbot.out"
+
+// manifest is manifest.json of the cloud assembly
+type manifest struct {
+	Artifacts map[string]struct {
+		Type        string `json:"type"`
+		DisplayName string `json:"displayName"`
+		Properties  struct {
+			TemplateFile  string `json:"templateFile"`
+			DirectoryName string `json:"directoryName"`
+		} `json:"properties"`
+		// IDs of the artifacts which are deployed before it
+		Dependencies []string `json:"dependencies"`
+	} `json:"artifacts"`
+}
+
+// stackArtifact is a stack in the cloud assembly
+type stackArtifact struct {
+	templatePath string
+	// display names of the stacks which the stack depends on
+	dependencies []string
+}
+
+// readAssembly returns the stacks in the cloud assembly by the display names such as "Stage/Stack".
+// Stacks in stages are read from the nested assemblies.
+func readAssembly(dir string) (map[string]stackArtifact, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest.json of %s: %v", filepath.Base(dir), err)
+	}
+	names := map[string]string{}
+	for id, artifact := range m.Artifacts {
+		if artifact.Type != "aws:cloudformation:stack" {
+			continue
+		}
+		names[id] = artifact.DisplayName
+		if names[id] == "" {
+			names[id] = id
+		}
+	}
+	stacks := map[string]stackArtifact{}
+	for id, artifact := range m.Artifacts {
+		switch artifact.Type {
+		case "aws:cloudformation:stack":
+			// dependencies include artifacts other than stacks such as assets
+			dependencies := []string{}
+			for _, dependency := range artifact.Dependencies {
+				if name, ok := names[dependency]; ok {
+					dependencies = append(dependencies, name)
+				}
+			}
+			stacks[names[id]] = stackArtifact{
+				templatePath: filepath.Join(dir, artifact.Properties.TemplateFile),
+				dependencies: dependencies,
+			}
+		case "cdk:cloud-assembly":
+			nested, err := readAssembly(filepath.Join(dir, artifact.Properties.DirectoryName))
+			if err != nil {
+				return nil, err
+			}
+			for name, stack := range nested {
+				stacks[name] = stack
+			}
+		}
+	}
+	return stacks, nil
+}
+
+// synth runs cdk synth and returns the stacks in the cloud assembly
+func synth(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (map[string]stackArtifact, error) {
+	// The repository is reused, so templates of the previous run are removed
+	if err := os.RemoveAll(filepath.Join(repoPath, synthOutputDir)); err != nil {
+		return nil, err
+	}
+	args := []string{"run", "cdk", "--", "synth"}
+	for _, stack := range stacks {
+		args = append(args, stack)
+	}
+	args = append(args, []string{"-o", synthOutputDir}...)
+	for k, v := range contexts {
+		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd := npmCommand(ctx, repoPath, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil || cmd.ProcessState.ExitCode() != 0 {
+		return nil, fmt.Errorf("cdk synth failed: %s %v", string(out), err)
+	}
+	// all stacks are written even if some stacks are specified
+	return readAssembly(filepath.Join(repoPath, synthOutputDir))
+}
+
+// Synth stacks and returns their templates by the display name of the stack such as "Stage/Stack".
+// If stacks are not specified, templates of all stacks are returned.
+func (*Client) Synth(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (map[string]string, error) {
+	artifacts, err := synth(ctx, repoPath, stacks, contexts)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	if len(stacks) != 0 {
+		names, _ = MatchStacks(stacks, names)
+	}
+	templates := map[string]string{}
+	for _, stack := range names {
+		template, err := os.ReadFile(artifacts[stack].templatePath)
+		if err != nil {
+			return nil, err
+		}
+		templates[stack] = string(template)
+	}
+	return templates, nil
+}
+
+// Dependencies returns the stacks and the ones they depend on recursively in sorted order,
+// which cdk deploy deploys together unless --exclusively is specified
+func (*Client) Dependencies(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) ([]string, error) {
+	artifacts, err := synth(ctx, repoPath, nil, contexts)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	matched, _ := MatchStacks(stacks, names)
+	visited := map[string]bool{}
+	for len(matched) != 0 {
+		stack := matched[0]
+		matched = matched[1:]
+		if visited[stack] {
+			continue
+		}
+		visited[stack] = true
+		matched = append(matched, artifacts[stack].dependencies...)
+	}
+	ret := []string{}
+	for stack := range visited {
+		ret = append(ret, stack)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// A line which cdk deploy outputs when a stack is deployed such as " ✅  Stack1"
+var finishedStackFormat = regexp.MustCompile(`(?m)^\s*✅\s+(\S+)`)
+
+// FinishedStacks returns stacks whose deployment finished in the output of cdk deploy
+func FinishedStacks(output string) []string {
+	stacks := []string{}
+	for _, match := range finishedStackFormat.FindAllStringSubmatch(output, -1) {
+		stacks = append(stacks, match[1])
+	}
+	return stacks
+}
+
+// MatchStacks returns the listed stacks matching any of the patterns in the order of listed,
+// and the patterns matching no stacks
+func MatchStacks(patterns []string, listed []string) ([]string, []string) {
+	matched := []string{}
+	unmatched := []string{}
+	for _, pattern := range patterns {
+		found := false
+		for _, stack := range listed {
+			// "*" doesn't match "/" as well as cdk, so "*" doesn't match stacks in stages
+			if ok, _ := path.Match(pattern, stack); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+	for _, stack := range listed {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, stack); ok {
+				matched = append(matched, stack)
+				break
+			}
+		}
+	}
+	return matched, unmatched
+}
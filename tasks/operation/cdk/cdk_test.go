@@ -1,20 +1,22 @@
 package cdk
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestClientSetup(t *testing.T) {
-	err := new(Client).Setup("./test_repository")
+	err := new(Client).Setup(context.Background(), "./test_repository")
 	assert.Nil(t, err)
 }
 
 func TestClientList(t *testing.T) {
-	lists, err := new(Client).List("./test_repository", map[string]string{"env": "stg"})
+	lists, err := new(Client).List(context.Background(), "./test_repository", map[string]string{"env": "stg"})
 	assert.Nil(t, err)
-	assert.Equal(t, []string{"Stack1", "Stack2"}, lists)
+	assert.Equal(t, []string{"Stack1", "Stack2", "Stage/Stack3"}, lists)
 }
 
 func TestClientDiff(t *testing.T) {
@@ -59,7 +61,7 @@ func TestClientDiff(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
-			result, hasDiff, err := new(Client).Diff("./test_repository", test.inStacks, map[string]string{"env": "stg"})
+			result, hasDiff, err := new(Client).Diff(context.Background(), "./test_repository", test.inStacks, map[string]string{"env": "stg"})
 			assert.Equal(t, test.expected.outResult, result)
 			assert.Equal(t, hasDiff, test.expected.outHasDiff)
 			assert.Equal(t, test.expected.isError, err != nil)
@@ -75,6 +77,8 @@ func TestClientDeploy(t *testing.T) {
 	tests := []struct {
 		title    string
 		inStacks []string
+		inFlags  []string
+		canceled bool
 		expected expected
 	}{
 		{
@@ -85,6 +89,67 @@ func TestClientDeploy(t *testing.T) {
 				isError:   false,
 			},
 		},
+		{
+			title:    "with_flags",
+			inStacks: []string{"stack1"},
+			inFlags:  []string{"--exclusively", "--hotswap"},
+			expected: expected{
+				outResult: "deploy: deploy stack1 --exclusively --hotswap --require-approval never -c env=stg",
+				isError:   false,
+			},
+		},
+		{
+			title:    "error",
+			inStacks: []string{"failStack"},
+			expected: expected{
+				outResult: "failed!",
+				isError:   true,
+			},
+		},
+		{
+			title:    "canceled",
+			inStacks: []string{"stack1"},
+			canceled: true,
+			expected: expected{
+				outResult: "failed!",
+				isError:   true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.canceled {
+				cancel()
+			} else {
+				defer cancel()
+			}
+			result, err := new(Client).Deploy(ctx, "./test_repository", test.inStacks, test.inFlags, map[string]string{"env": "stg"})
+			assert.Equal(t, test.expected.outResult, result)
+			assert.Equal(t, test.expected.isError, err != nil)
+		})
+	}
+}
+
+func TestClientDestroy(t *testing.T) {
+	type expected struct {
+		outResult string
+		isError   bool
+	}
+	tests := []struct {
+		title    string
+		inStacks []string
+		expected expected
+	}{
+		{
+			title:    "success",
+			inStacks: []string{"stack1", "stack2"},
+			expected: expected{
+				outResult: "destroy: destroy stack1 stack2 --force -c env=stg",
+				isError:   false,
+			},
+		},
 		{
 			title:    "error",
 			inStacks: []string{"failStack"},
@@ -97,9 +162,112 @@ func TestClientDeploy(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
-			result, err := new(Client).Deploy("./test_repository", test.inStacks, map[string]string{"env": "stg"})
+			result, err := new(Client).Destroy(context.Background(), "./test_repository", test.inStacks, map[string]string{"env": "stg"})
 			assert.Equal(t, test.expected.outResult, result)
 			assert.Equal(t, test.expected.isError, err != nil)
 		})
 	}
 }
+
+func TestClientSynth(t *testing.T) {
+	tests := []struct {
+		title    string
+		inStacks []string
+		expected map[string]string
+	}{
+		{
+			title:    "all_stacks",
+			inStacks: nil,
+			expected: map[string]string{
+				"Stack1":       "{\"Description\": \"Stack1\"}\n",
+				"Stack2":       "{\"Description\": \"Stack2\"}\n",
+				"Stage/Stack3": "{\"Description\": \"Stage/Stack3\"}\n",
+			},
+		},
+		{
+			title:    "specified_stacks",
+			inStacks: []string{"Stack2"},
+			expected: map[string]string{
+				"Stack2": "{\"Description\": \"Stack2\"}\n",
+			},
+		},
+		{
+			title:    "patterns",
+			inStacks: []string{"*1", "Stack?"},
+			expected: map[string]string{
+				"Stack1": "{\"Description\": \"Stack1\"}\n",
+				"Stack2": "{\"Description\": \"Stack2\"}\n",
+			},
+		},
+		{
+			title:    "stacks_in_stage",
+			inStacks: []string{"Stage/*"},
+			expected: map[string]string{
+				"Stage/Stack3": "{\"Description\": \"Stage/Stack3\"}\n",
+			},
+		},
+		{
+			title:    "unmatched_pattern",
+			inStacks: []string{"Prod-*"},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			templates, err := new(Client).Synth(context.Background(), "./test_repository", test.inStacks, map[string]string{"env": "stg"})
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, templates)
+		})
+	}
+}
+
+func TestFinishedStacks(t *testing.T) {
+	output := strings.Join([]string{
+		"Stack1: deploying...",
+		"",
+		" ✅  Stack1",
+		"",
+		"Outputs:",
+		"Stack2: deploying...",
+		" ✅  Stack2 (no changes)",
+		"Stack3: deploying...",
+	}, "\n")
+	assert.Equal(t, []string{"Stack1", "Stack2"}, FinishedStacks(output))
+	assert.Equal(t, []string{}, FinishedStacks("failed!"))
+}
+
+func TestClientDependencies(t *testing.T) {
+	tests := []struct {
+		title    string
+		inStacks []string
+		expected []string
+	}{
+		{
+			title:    "with_dependencies",
+			inStacks: []string{"Stack2"},
+			expected: []string{"Stack1", "Stack2"},
+		},
+		{
+			title:    "no_dependencies",
+			inStacks: []string{"Stack1", "Stage/*"},
+			expected: []string{"Stack1", "Stage/Stack3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			client := Client{}
+			stacks, err := client.Dependencies(context.Background(), "./test_repository", test.inStacks, map[string]string{})
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, stacks)
+		})
+	}
+}
+
+func TestMatchStacks(t *testing.T) {
+	listed := []string{"Dev-Api", "Prod-Api", "Prod-Web", "Stage/Prod-Db"}
+	matched, unmatched := MatchStacks([]string{"Prod-Web", "Prod-*", "Stage/*", "Stg-*"}, listed)
+	assert.Equal(t, []string{"Prod-Api", "Prod-Web", "Stage/Prod-Db"}, matched)
+	assert.Equal(t, []string{"Stg-*"}, unmatched)
+}
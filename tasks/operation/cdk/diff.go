@@ -0,0 +1,305 @@
+package cdk
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ChangeType is the type of a change shown as [+], [-] and [~] in cdk diff
+type ChangeType string
+
+// ChangeType
+const (
+	ChangeTypeAdd    ChangeType = "add"
+	ChangeTypeRemove ChangeType = "remove"
+	ChangeTypeUpdate ChangeType = "update"
+)
+
+// Impact is the impact of a resource change shown at the end of the line
+type Impact string
+
+// Impact
+const (
+	ImpactNone       Impact = ""
+	ImpactMayReplace Impact = "may be replaced"
+	ImpactReplace    Impact = "replace"
+	ImpactDestroy    Impact = "destroy"
+	ImpactOrphan     Impact = "orphan"
+)
+
+// StackDiff is the differences of a stack in the output of cdk diff
+type StackDiff struct {
+	Stack string
+	// Lines of the stack in the output
+	Output         string
+	Resources      []ResourceChange
+	IAMStatements  []IAMStatementChange
+	IAMPolicies    []IAMPolicyChange
+	SecurityGroups []SecurityGroupChange
+	Parameters     []Change
+	Outputs        []Change
+	// Conditions, Mappings, Metadata and Other Changes
+	Others []Change
+}
+
+// ResourceChange is a change of a resource such as "[~] AWS::RDS::DBInstance Db DbABCD1234 replace"
+type ResourceChange struct {
+	Type         ChangeType
+	ResourceType string
+	// Construct path. Empty if cdk doesn't know it.
+	Path      string
+	LogicalID string
+	Impact    Impact
+}
+
+// IAMStatementChange is a row of "IAM Statement Changes"
+type IAMStatementChange struct {
+	Type      ChangeType
+	Resource  string
+	Effect    string
+	Action    string
+	Principal string
+	Condition string
+}
+
+// IAMPolicyChange is a row of "IAM Policy Changes"
+type IAMPolicyChange struct {
+	Type             ChangeType
+	Resource         string
+	ManagedPolicyARN string
+}
+
+// SecurityGroupChange is a row of "Security Group Changes"
+type SecurityGroupChange struct {
+	Type      ChangeType
+	Group     string
+	Direction string
+	Protocol  string
+	Peer      string
+}
+
+// Change is a change of a parameter, an output and so on such as "[+] Output BucketName BucketName: {...}"
+type Change struct {
+	Type ChangeType
+	// Parameter, Output, Condition, Mapping, Metadata or Unknown
+	Kind      string
+	LogicalID string
+}
+
+// HasChanges returns whether the stack has any changes
+func (d *StackDiff) HasChanges() bool {
+	return len(d.Resources) != 0 ||
+		len(d.IAMStatements) != 0 ||
+		len(d.IAMPolicies) != 0 ||
+		len(d.SecurityGroups) != 0 ||
+		len(d.Parameters) != 0 ||
+		len(d.Outputs) != 0 ||
+		len(d.Others) != 0
+}
+
+// IsReplacedOrRemoved returns whether the resource is replaced or removed
+func (c *ResourceChange) IsReplacedOrRemoved() bool {
+	return c.Type == ChangeTypeRemove || c.Impact == ImpactReplace || c.Impact == ImpactMayReplace
+}
+
+var (
+	ansiEscapeFormat = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	changeTypes      = map[string]ChangeType{
+		"[+]": ChangeTypeAdd,
+		"[-]": ChangeTypeRemove,
+		"[~]": ChangeTypeUpdate,
+		"+":   ChangeTypeAdd,
+		"-":   ChangeTypeRemove,
+	}
+)
+
+// ParseDiff parses the output of cdk diff into the differences of each stack in the order of the output
+func ParseDiff(output string) []StackDiff {
+	diffs := []StackDiff{}
+	var (
+		stack   *StackDiff
+		section string
+		table   *diffTable
+	)
+	for _, line := range strings.Split(ansiEscapeFormat.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if strings.HasPrefix(line, "Stack ") {
+			diffs = append(diffs, StackDiff{Stack: strings.Fields(line)[1]})
+			stack = &diffs[len(diffs)-1]
+			section = ""
+		}
+		// summary e.g. "✨  Number of stacks with differences: 1" is not a part of the stack
+		if strings.Contains(line, "Number of stacks with differences") {
+			continue
+		}
+		if len(diffs) != 0 {
+			diffs[len(diffs)-1].Output += line + "\n"
+		}
+		if stack == nil || strings.TrimSpace(line) == "" || strings.HasPrefix(line, "Stack ") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "There were no differences"):
+			stack = nil
+		case strings.HasPrefix(line, "┌"):
+			table = &diffTable{}
+		case strings.HasPrefix(line, "│"):
+			if table != nil {
+				table.addRow(line)
+			}
+		case strings.HasPrefix(line, "├"):
+			if table != nil {
+				table.headerDone = true
+			}
+		case strings.HasPrefix(line, "└"):
+			if table != nil {
+				stack.addTable(section, table)
+			}
+			table = nil
+		case strings.HasPrefix(line, "[") && section == "Resources":
+			stack.addResourceChange(line)
+		case strings.HasPrefix(line, "["):
+			stack.addChange(section, line)
+		case strings.HasPrefix(line, " ") && section == "Resources" && len(stack.Resources) != 0:
+			// properties of the resource such as " └─ [~] DBInstanceIdentifier (requires replacement)"
+			last := &stack.Resources[len(stack.Resources)-1]
+			if last.Impact == ImpactNone {
+				if strings.Contains(line, "(requires replacement)") {
+					last.Impact = ImpactReplace
+				} else if strings.Contains(line, "(may cause replacement)") {
+					last.Impact = ImpactMayReplace
+				}
+			}
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "("):
+			// details of a change or notes such as "(NOTE: There may be security-related changes not in this list...)"
+		default:
+			section = line
+		}
+	}
+	for i := range diffs {
+		diffs[i].Output = strings.Trim(diffs[i].Output, "\n")
+	}
+	return diffs
+}
+
+// addResourceChange parses "[~] AWS::Lambda::Function Fn Fn9270CBC0 may be replaced"
+func (d *StackDiff) addResourceChange(line string) {
+	fields := strings.Fields(line)
+	changeType, ok := changeTypes[fields[0]]
+	if !ok || len(fields) < 3 {
+		return
+	}
+	change := ResourceChange{
+		Type:         changeType,
+		ResourceType: fields[1],
+	}
+	fields = fields[2:]
+	rest := strings.Join(fields, " ")
+	for _, impact := range []Impact{ImpactMayReplace, ImpactReplace, ImpactDestroy, ImpactOrphan} {
+		if len(fields) > 1 && strings.HasSuffix(rest, " "+string(impact)) {
+			change.Impact = impact
+			fields = strings.Fields(strings.TrimSuffix(rest, " "+string(impact)))
+			break
+		}
+	}
+	change.LogicalID = fields[len(fields)-1]
+	if len(fields) > 1 {
+		change.Path = strings.Join(fields[:len(fields)-1], " ")
+	}
+	d.Resources = append(d.Resources, change)
+}
+
+// addChange parses "[+] Parameter BootstrapVersion BootstrapVersion: {...}" in the section
+func (d *StackDiff) addChange(section string, line string) {
+	name, _, _ := strings.Cut(line, ":")
+	fields := strings.Fields(name)
+	changeType, ok := changeTypes[fields[0]]
+	if !ok || len(fields) < 3 {
+		return
+	}
+	change := Change{
+		Type:      changeType,
+		Kind:      fields[1],
+		LogicalID: fields[len(fields)-1],
+	}
+	switch section {
+	case "Parameters":
+		d.Parameters = append(d.Parameters, change)
+	case "Outputs":
+		d.Outputs = append(d.Outputs, change)
+	default:
+		d.Others = append(d.Others, change)
+	}
+}
+
+// addTable adds the rows of the table in the section
+func (d *StackDiff) addTable(section string, table *diffTable) {
+	for _, row := range table.rows {
+		changeType, ok := changeTypes[row[0]]
+		if !ok {
+			continue
+		}
+		cell := func(column string) string {
+			for i, header := range table.header {
+				if header == column && i < len(row) {
+					return row[i]
+				}
+			}
+			return ""
+		}
+		switch section {
+		case "IAM Statement Changes":
+			d.IAMStatements = append(d.IAMStatements, IAMStatementChange{
+				Type:      changeType,
+				Resource:  cell("Resource"),
+				Effect:    cell("Effect"),
+				Action:    cell("Action"),
+				Principal: cell("Principal"),
+				Condition: cell("Condition"),
+			})
+		case "IAM Policy Changes":
+			d.IAMPolicies = append(d.IAMPolicies, IAMPolicyChange{
+				Type:             changeType,
+				Resource:         cell("Resource"),
+				ManagedPolicyARN: cell("Managed Policy ARN"),
+			})
+		case "Security Group Changes":
+			d.SecurityGroups = append(d.SecurityGroups, SecurityGroupChange{
+				Type:      changeType,
+				Group:     cell("Group"),
+				Direction: cell("Dir"),
+				Protocol:  cell("Protocol"),
+				Peer:      cell("Peer"),
+			})
+		}
+	}
+}
+
+// diffTable is a table of IAM and security group changes drawn with box-drawing characters
+type diffTable struct {
+	header     []string
+	headerDone bool
+	rows       [][]string
+}
+
+// addRow adds "│ + │ ${Role.Arn} │ Allow │ sts:AssumeRole │". A row whose first cell is empty continues the previous row.
+func (t *diffTable) addRow(line string) {
+	cells := strings.Split(strings.Trim(line, "│"), "│")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	if !t.headerDone {
+		t.header = cells
+		return
+	}
+	if cells[0] == "" && len(t.rows) != 0 {
+		last := t.rows[len(t.rows)-1]
+		for i := 1; i < len(cells) && i < len(last); i++ {
+			if cells[i] != "" {
+				last[i] = strings.TrimPrefix(last[i]+"\n"+cells[i], "\n")
+			}
+		}
+		return
+	}
+	t.rows = append(t.rows, cells)
+}
@@ -0,0 +1,161 @@
+package cdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Output of a stack having all kinds of changes
+const stack1Diff = `Stack Stack1
+IAM Statement Changes
+┌───┬─────────────────┬────────┬────────────────┬──────────────────────────────┬───────────┐
+│   │ Resource        │ Effect │ Action         │ Principal                    │ Condition │
+├───┼─────────────────┼────────┼────────────────┼──────────────────────────────┼───────────┤
+│ + │ ${Fn/Role.Arn}  │ Allow  │ sts:AssumeRole │ Service:lambda.amazonaws.com │           │
+├───┼─────────────────┼────────┼────────────────┼──────────────────────────────┼───────────┤
+│ - │ ${Bucket.Arn}   │ Allow  │ s3:GetObject   │ AWS:${Fn/Role}               │           │
+│   │ ${Bucket.Arn}/* │        │ s3:PutObject   │                              │           │
+└───┴─────────────────┴────────┴────────────────┴──────────────────────────────┴───────────┘
+IAM Policy Changes
+┌───┬──────────────┬────────────────────────────────────────────────────────────────────────────────┐
+│   │ Resource     │ Managed Policy ARN                                                             │
+├───┼──────────────┼────────────────────────────────────────────────────────────────────────────────┤
+│ + │ ${Fn/Role}   │ arn:${AWS::Partition}:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole │
+└───┴──────────────┴────────────────────────────────────────────────────────────────────────────────┘
+Security Group Changes
+┌───┬──────────────────┬─────┬────────────┬─────────────────┐
+│   │ Group            │ Dir │ Protocol   │ Peer            │
+├───┼──────────────────┼─────┼────────────┼─────────────────┤
+│ + │ ${Sg.GroupId}    │ In  │ TCP 443    │ Everyone (IPv4) │
+└───┴──────────────────┴─────┴────────────┴─────────────────┘
+(NOTE: There may be security-related changes not in this list. See https://github.com/aws/aws-cdk/issues/1299)
+
+Parameters
+[+] Parameter BootstrapVersion BootstrapVersion: {"Type":"AWS::SSM::Parameter::Value<String>","Default":"/cdk-bootstrap/hnb659fds/version"}
+
+Conditions
+[+] Condition CDKMetadataAvailable: {"Fn::Or":[]}
+
+Resources
+[+] AWS::IAM::Role Fn/Role FnRole03C8B6F4
+[~] AWS::Lambda::Function Fn Fn9270CBC0
+ └─ [~] Code
+     └─ [~] .S3Key:
+         ├─ [-] old.zip
+         └─ [+] new.zip
+[~] AWS::RDS::DBInstance Db DbABCD1234 replace
+ └─ [~] DBInstanceIdentifier (requires replacement)
+     ├─ [-] db1
+     └─ [+] db2
+[~] AWS::EC2::Instance Instance InstanceC1063A87
+ └─ [~] UserData (may cause replacement)
+[-] AWS::SNS::Topic Topic Topic2A4C2B8F destroy
+[-] AWS::S3::Bucket Logs LogsBucketA1B2C3D4 orphan
+[~] AWS::DynamoDB::Table Table TableCD117FA1 may be replaced
+[+] AWS::SQS::Queue QueueE1D3C4B2
+
+Outputs
+[+] Output BucketName BucketName: {"Value":{"Ref":"Bucket83908E77"}}
+[-] Output TopicArn TopicArn: {"Value":{"Ref":"Topic2A4C2B8F"}}
+
+Other Changes
+[+] Unknown Rules: {"CheckBootstrapVersion":{}}`
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		title string
+		in    string
+		out   []StackDiff
+	}{
+		{
+			title: "resources_iam_and_security_groups",
+			in:    stack1Diff + "\n\nStack Stack2\nThere were no differences\n\n✨  Number of stacks with differences: 1",
+			out: []StackDiff{
+				{
+					Stack:  "Stack1",
+					Output: stack1Diff,
+					Resources: []ResourceChange{
+						{Type: ChangeTypeAdd, ResourceType: "AWS::IAM::Role", Path: "Fn/Role", LogicalID: "FnRole03C8B6F4"},
+						{Type: ChangeTypeUpdate, ResourceType: "AWS::Lambda::Function", Path: "Fn", LogicalID: "Fn9270CBC0"},
+						{Type: ChangeTypeUpdate, ResourceType: "AWS::RDS::DBInstance", Path: "Db", LogicalID: "DbABCD1234", Impact: ImpactReplace},
+						{Type: ChangeTypeUpdate, ResourceType: "AWS::EC2::Instance", Path: "Instance", LogicalID: "InstanceC1063A87", Impact: ImpactMayReplace},
+						{Type: ChangeTypeRemove, ResourceType: "AWS::SNS::Topic", Path: "Topic", LogicalID: "Topic2A4C2B8F", Impact: ImpactDestroy},
+						{Type: ChangeTypeRemove, ResourceType: "AWS::S3::Bucket", Path: "Logs", LogicalID: "LogsBucketA1B2C3D4", Impact: ImpactOrphan},
+						{Type: ChangeTypeUpdate, ResourceType: "AWS::DynamoDB::Table", Path: "Table", LogicalID: "TableCD117FA1", Impact: ImpactMayReplace},
+						{Type: ChangeTypeAdd, ResourceType: "AWS::SQS::Queue", LogicalID: "QueueE1D3C4B2"},
+					},
+					IAMStatements: []IAMStatementChange{
+						{Type: ChangeTypeAdd, Resource: "${Fn/Role.Arn}", Effect: "Allow", Action: "sts:AssumeRole", Principal: "Service:lambda.amazonaws.com"},
+						{Type: ChangeTypeRemove, Resource: "${Bucket.Arn}\n${Bucket.Arn}/*", Effect: "Allow", Action: "s3:GetObject\ns3:PutObject", Principal: "AWS:${Fn/Role}"},
+					},
+					IAMPolicies: []IAMPolicyChange{
+						{Type: ChangeTypeAdd, Resource: "${Fn/Role}", ManagedPolicyARN: "arn:${AWS::Partition}:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"},
+					},
+					SecurityGroups: []SecurityGroupChange{
+						{Type: ChangeTypeAdd, Group: "${Sg.GroupId}", Direction: "In", Protocol: "TCP 443", Peer: "Everyone (IPv4)"},
+					},
+					Parameters: []Change{
+						{Type: ChangeTypeAdd, Kind: "Parameter", LogicalID: "BootstrapVersion"},
+					},
+					Outputs: []Change{
+						{Type: ChangeTypeAdd, Kind: "Output", LogicalID: "BucketName"},
+						{Type: ChangeTypeRemove, Kind: "Output", LogicalID: "TopicArn"},
+					},
+					Others: []Change{
+						{Type: ChangeTypeAdd, Kind: "Condition", LogicalID: "CDKMetadataAvailable"},
+						{Type: ChangeTypeAdd, Kind: "Unknown", LogicalID: "Rules"},
+					},
+				},
+				{
+					Stack:  "Stack2",
+					Output: "Stack Stack2\nThere were no differences",
+				},
+			},
+		},
+		{
+			title: "stage_and_colored",
+			in:    "Stack Stage/Stack3 (Stage-Stack3)\nResources\n\x1b[31m[-]\x1b[39m \x1b[36mAWS::SQS::Queue\x1b[39m Queue \x1b[90mQueue4A7E3555\x1b[39m \x1b[31m\x1b[1m\x1b[3mdestroy\x1b[23m\x1b[22m\x1b[39m",
+			out: []StackDiff{
+				{
+					Stack:  "Stage/Stack3",
+					Output: "Stack Stage/Stack3 (Stage-Stack3)\nResources\n[-] AWS::SQS::Queue Queue Queue4A7E3555 destroy",
+					Resources: []ResourceChange{
+						{Type: ChangeTypeRemove, ResourceType: "AWS::SQS::Queue", Path: "Queue", LogicalID: "Queue4A7E3555", Impact: ImpactDestroy},
+					},
+				},
+			},
+		},
+		{
+			title: "no_stacks",
+			in:    "fail!",
+			out:   []StackDiff{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.out, ParseDiff(test.in))
+		})
+	}
+}
+
+func TestStackDiff_HasChanges(t *testing.T) {
+	assert.False(t, (&StackDiff{Stack: "Stack1"}).HasChanges())
+	assert.True(t, (&StackDiff{Stack: "Stack1", Outputs: []Change{{Type: ChangeTypeAdd, Kind: "Output", LogicalID: "Out"}}}).HasChanges())
+}
+
+func TestResourceChange_IsReplacedOrRemoved(t *testing.T) {
+	tests := []struct {
+		in  ResourceChange
+		out bool
+	}{
+		{in: ResourceChange{Type: ChangeTypeAdd}, out: false},
+		{in: ResourceChange{Type: ChangeTypeUpdate}, out: false},
+		{in: ResourceChange{Type: ChangeTypeUpdate, Impact: ImpactMayReplace}, out: true},
+		{in: ResourceChange{Type: ChangeTypeUpdate, Impact: ImpactReplace}, out: true},
+		{in: ResourceChange{Type: ChangeTypeRemove, Impact: ImpactDestroy}, out: true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.out, test.in.IsReplacedOrRemoved())
+	}
+}
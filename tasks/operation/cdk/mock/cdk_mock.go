@@ -10,6 +10,7 @@
 package mock
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -38,25 +39,55 @@ func (m *MockClienter) EXPECT() *MockClienterMockRecorder {
 	return m.recorder
 }
 
+// Dependencies mocks base method.
+func (m *MockClienter) Dependencies(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dependencies", ctx, repoPath, stacks, contexts)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dependencies indicates an expected call of Dependencies.
+func (mr *MockClienterMockRecorder) Dependencies(ctx, repoPath, stacks, contexts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dependencies", reflect.TypeOf((*MockClienter)(nil).Dependencies), ctx, repoPath, stacks, contexts)
+}
+
 // Deploy mocks base method.
-func (m *MockClienter) Deploy(repoPath string, stacks []string, contexts map[string]string) (string, error) {
+func (m *MockClienter) Deploy(ctx context.Context, repoPath string, stacks, flags []string, contexts map[string]string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Deploy", repoPath, stacks, contexts)
+	ret := m.ctrl.Call(m, "Deploy", ctx, repoPath, stacks, flags, contexts)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Deploy indicates an expected call of Deploy.
-func (mr *MockClienterMockRecorder) Deploy(repoPath, stacks, contexts any) *gomock.Call {
+func (mr *MockClienterMockRecorder) Deploy(ctx, repoPath, stacks, flags, contexts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deploy", reflect.TypeOf((*MockClienter)(nil).Deploy), ctx, repoPath, stacks, flags, contexts)
+}
+
+// Destroy mocks base method.
+func (m *MockClienter) Destroy(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Destroy", ctx, repoPath, stacks, contexts)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Destroy indicates an expected call of Destroy.
+func (mr *MockClienterMockRecorder) Destroy(ctx, repoPath, stacks, contexts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deploy", reflect.TypeOf((*MockClienter)(nil).Deploy), repoPath, stacks, contexts)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockClienter)(nil).Destroy), ctx, repoPath, stacks, contexts)
 }
 
 // Diff mocks base method.
-func (m *MockClienter) Diff(repoPath string, stacks []string, contexts map[string]string) (string, bool, error) {
+func (m *MockClienter) Diff(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (string, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Diff", repoPath, stacks, contexts)
+	ret := m.ctrl.Call(m, "Diff", ctx, repoPath, stacks, contexts)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -64,36 +95,51 @@ func (m *MockClienter) Diff(repoPath string, stacks []string, contexts map[strin
 }
 
 // Diff indicates an expected call of Diff.
-func (mr *MockClienterMockRecorder) Diff(repoPath, stacks, contexts any) *gomock.Call {
+func (mr *MockClienterMockRecorder) Diff(ctx, repoPath, stacks, contexts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockClienter)(nil).Diff), repoPath, stacks, contexts)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockClienter)(nil).Diff), ctx, repoPath, stacks, contexts)
 }
 
 // List mocks base method.
-func (m *MockClienter) List(repoPath string, contexts map[string]string) ([]string, error) {
+func (m *MockClienter) List(ctx context.Context, repoPath string, contexts map[string]string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List", repoPath, contexts)
+	ret := m.ctrl.Call(m, "List", ctx, repoPath, contexts)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // List indicates an expected call of List.
-func (mr *MockClienterMockRecorder) List(repoPath, contexts any) *gomock.Call {
+func (mr *MockClienterMockRecorder) List(ctx, repoPath, contexts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClienter)(nil).List), repoPath, contexts)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClienter)(nil).List), ctx, repoPath, contexts)
 }
 
 // Setup mocks base method.
-func (m *MockClienter) Setup(repoPath string) error {
+func (m *MockClienter) Setup(ctx context.Context, repoPath string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Setup", repoPath)
+	ret := m.ctrl.Call(m, "Setup", ctx, repoPath)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Setup indicates an expected call of Setup.
-func (mr *MockClienterMockRecorder) Setup(repoPath any) *gomock.Call {
+func (mr *MockClienterMockRecorder) Setup(ctx, repoPath any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Setup", reflect.TypeOf((*MockClienter)(nil).Setup), ctx, repoPath)
+}
+
+// Synth mocks base method.
+func (m *MockClienter) Synth(ctx context.Context, repoPath string, stacks []string, contexts map[string]string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Synth", ctx, repoPath, stacks, contexts)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Synth indicates an expected call of Synth.
+func (mr *MockClienterMockRecorder) Synth(ctx, repoPath, stacks, contexts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Setup", reflect.TypeOf((*MockClienter)(nil).Setup), repoPath)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Synth", reflect.TypeOf((*MockClienter)(nil).Synth), ctx, repoPath, stacks, contexts)
 }
@@ -1,5 +1,5 @@
 if [ "$1" == "list" ]; then
-  echo -e "Stack1\nStack2"
+  echo -e "Stack1\nStack2\nStage/Stack3 (Stage-Stack3)"
 elif [ "$2" == "failStack" ]; then
   echo "failed!"
   exit 2
@@ -11,4 +11,25 @@ elif [ "$1" == "diff" ]; then
   exit 0 # has no diff
 elif [ "$1" == "deploy" ]; then
   echo -e "deploy: $@"
+elif [ "$1" == "destroy" ]; then
+  echo -e "destroy: $@"
+elif [ "$1" == "synth" ]; then
+  mkdir -p cdkbot.out/assembly-Stage
+  for stack in Stack1 Stack2; do
+    echo "{\"Description\": \"$stack\"}" > cdkbot.out/$stack.template.json
+  done
+  echo "{\"Description\": \"Stage/Stack3\"}" > cdkbot.out/assembly-Stage/StageStack3A1B2C3D4.template.json
+  cat << MANIFEST > cdkbot.out/manifest.json
+{"artifacts": {
+  "Stack1": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "Stack1.template.json"}, "displayName": "Stack1"},
+  "Stack2": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "Stack2.template.json"}, "dependencies": ["Stack1", "Stack2.assets"]},
+  "Stack2.assets": {"type": "cdk:asset-manifest", "properties": {"file": "Stack2.assets.json"}},
+  "assembly-Stage": {"type": "cdk:cloud-assembly", "properties": {"directoryName": "assembly-Stage"}, "displayName": "Stage"}
+}}
+MANIFEST
+  cat << MANIFEST > cdkbot.out/assembly-Stage/manifest.json
+{"artifacts": {
+  "StageStack3A1B2C3D4": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "StageStack3A1B2C3D4.template.json"}, "displayName": "Stage/Stack3"}
+}}
+MANIFEST
 fi
\ No newline at end of file
@@ -0,0 +1,21 @@
+package command
+
+import (
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+)
+
+// approvalsOnHead counts users other than the author whose latest review is an approval on the head commit.
+// Approvals on older commits are ignored.
+func approvalsOnHead(reviews []platform.Review, pr *platform.PullRequest) int {
+	latest := map[string]platform.Review{}
+	for _, review := range reviews {
+		latest[review.User] = review
+	}
+	approvals := 0
+	for user, review := range latest {
+		if user != pr.Author && review.Approved && review.CommitHash == pr.HeadCommitHash {
+			approvals++
+		}
+	}
+	return approvals
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalsOnHead(t *testing.T) {
+	pr := &platform.PullRequest{Author: "author", HeadCommitHash: "headhash"}
+	tests := []struct {
+		title string
+		in    []platform.Review
+		out   int
+	}{
+		{
+			title: "approved_on_head",
+			in: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "headhash"},
+				{User: "bob", Approved: true, CommitHash: "headhash"},
+			},
+			out: 2,
+		},
+		{
+			title: "approved_on_older_commit",
+			in: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "oldhash"},
+			},
+			out: 0,
+		},
+		{
+			title: "approved_again_on_head",
+			in: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "oldhash"},
+				{User: "alice", Approved: true, CommitHash: "headhash"},
+			},
+			out: 1,
+		},
+		{
+			title: "changes_requested_after_approval",
+			in: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "headhash"},
+				{User: "alice", Approved: false, CommitHash: "headhash"},
+			},
+			out: 0,
+		},
+		{
+			title: "approved_by_author",
+			in: []platform.Review{
+				{User: "author", Approved: true, CommitHash: "headhash"},
+			},
+			out: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.out, approvalsOnHead(test.in, pr))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often running deploys check /cancel comments
+const cancelCheckInterval = 15 * time.Second
+
+// The line added to the comment of the canceled deploy
+const canceledByPrefix = "Canceled by "
+
+// Cancel replies if no running deploy is canceled by /cancel.
+// /cancel comments are queued after the running job, so the running job checks them by itself
+// and they have been handled when this is run.
+func (r *Runner) Cancel(ctx context.Context, userName string) error {
+	comments, err := r.platform.ListComments(ctx)
+	if err != nil {
+		return err
+	}
+	last := -1
+	for i, comment := range comments {
+		if isCancelCommand(comment.Body) {
+			last = i
+		}
+	}
+	for _, comment := range comments[last+1:] {
+		if strings.Contains(comment.Body, "\n"+canceledByPrefix) {
+			return nil
+		}
+	}
+	cfg, _, err := r.readBaseConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.IsUserAllowedDeploy(userName) {
+		return r.platform.CreateComment(ctx, fmt.Sprintf("user %s is not allowed to cancel", userName))
+	}
+	return r.platform.CreateComment(ctx, "No running deploys to cancel. /cancel stops /deploy and /rollback running on this PR.")
+}
+
+func isCancelCommand(body string) bool {
+	return body == "/cancel" || strings.HasPrefix(body, "/cancel ")
+}
+
+// watchCancel returns ctx canceled when a user allowed to deploy comments /cancel after it is called.
+// The returned function must be called after the job, and it returns the user who canceled or empty.
+func (r *Runner) watchCancel(ctx context.Context, cfg *config.Config) (context.Context, func() string, error) {
+	comments, err := r.platform.ListComments(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var lastID int64
+	for _, comment := range comments {
+		if comment.ID > lastID {
+			lastID = comment.ID
+		}
+	}
+	interval := r.cancelCheckInterval
+	if interval == 0 {
+		interval = cancelCheckInterval
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	var (
+		mu         sync.Mutex
+		canceledBy string
+	)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				comments, err := r.platform.ListComments(ctx)
+				if err != nil {
+					r.logger.Error("list comments error", zap.Error(err))
+					continue
+				}
+				if user := findCancelUser(comments, lastID, cfg); user != "" {
+					mu.Lock()
+					canceledBy = user
+					mu.Unlock()
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return jobCtx, func() string {
+		close(done)
+		<-stopped
+		cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		return canceledBy
+	}, nil
+}
+
+// findCancelUser returns the user who commented /cancel after the comment of afterID
+// and is allowed to deploy, or empty if there is no such comment
+func findCancelUser(comments []platform.Comment, afterID int64, cfg *config.Config) string {
+	for _, comment := range comments {
+		if comment.ID > afterID && isCancelCommand(comment.Body) && cfg.IsUserAllowedDeploy(comment.User) {
+			return comment.User
+		}
+	}
+	return ""
+}
+
+// splitFinishedStacks splits stacks into finished and not finished ones by the output of cdk deploy
+func splitFinishedStacks(stacks []string, output string) ([]string, []string) {
+	finished := map[string]bool{}
+	for _, stack := range cdk.FinishedStacks(output) {
+		finished[stack] = true
+	}
+	finishedStacks := []string{}
+	notFinishedStacks := []string{}
+	for _, stack := range stacks {
+		if finished[stack] {
+			finishedStacks = append(finishedStacks, stack)
+		} else {
+			notFinishedStacks = append(notFinishedStacks, stack)
+		}
+	}
+	return finishedStacks, notFinishedStacks
+}
+
+// cancelReport returns lines telling who canceled the deploy and which stacks finished
+func cancelReport(canceledBy string, stacks []string, output string) string {
+	finishedStacks, notFinishedStacks := splitFinishedStacks(stacks, output)
+	lines := []string{fmt.Sprintf("%s%s.", canceledByPrefix, canceledBy)}
+	if len(finishedStacks) == 0 {
+		lines = append(lines, "Finished stacks: none")
+	} else {
+		lines = append(lines, fmt.Sprintf("Finished stacks: %s", strings.Join(finishedStacks, ", ")))
+	}
+	if len(notFinishedStacks) != 0 {
+		lines = append(lines, fmt.Sprintf(
+			"Not finished stacks: %s. The stack being deployed may continue to be updated by CloudFormation.",
+			strings.Join(notFinishedStacks, ", "),
+		))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cdkMock "github.com/sambaiz/cdkbot/tasks/operation/cdk/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_Deploy_Canceled(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	platformClient := platformMock.NewMockClienter(ctrl)
+	gitClient := gitMock.NewMockClienter(ctrl)
+	configClient := configMock.NewMockReaderer(ctrl)
+	cdkClient := cdkMock.NewMockClienter(ctrl)
+
+	cfg := config.Config{
+		CDKRoot: ".",
+		Targets: map[string]config.Target{
+			"develop": {Contexts: map[string]string{"env": "stg"}},
+		},
+		DeployUsers: []string{"sambaiz"},
+	}
+	// hasOutdatedDiffs()
+	platformClient.EXPECT().GetPullRequest(ctx).Return(&platform.PullRequest{}, nil)
+	// updateStatus()
+	platformClient.EXPECT().SetStatus(ctx, constant.StateRunning, "").Return(nil)
+	platformClient.EXPECT().AddLabel(ctx, constant.LabelRunning).Return(nil)
+	platformClient.EXPECT().SetStatus(ctx, constant.StateNotMergeReady, "Canceled by sambaiz").Return(nil)
+	platformClient.EXPECT().RemoveLabel(ctx, constant.LabelRunning).Return(nil)
+	constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+		Number:         1,
+		BaseBranch:     "develop",
+		BaseCommitHash: "basehash",
+		HeadCommitHash: "headhash",
+	})
+	platformClient.EXPECT().GetOpenPullRequests(ctx).Return([]platform.PullRequest{}, nil)
+
+	deployComment := platform.Comment{ID: 1, Body: "/deploy Stack1 Stack2", User: "sambaiz"}
+	platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{deployComment}, nil)
+	platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{
+		deployComment,
+		// users not allowed to deploy can't cancel
+		{ID: 2, Body: "/cancel", User: "foobar"},
+		{ID: 3, Body: "/cancel", User: "sambaiz"},
+	}, nil).AnyTimes()
+	cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), cfg.CDKRoot)
+	cdkClient.EXPECT().Dependencies(ctx, cdkPath, []string{"Stack1", "Stack2"}, cfg.Targets["develop"].Contexts).Return([]string{"Stack1", "Stack2"}, nil)
+	cdkClient.EXPECT().Diff(ctx, cdkPath, []string{"Stack1", "Stack2"}, cfg.Targets["develop"].Contexts).Return("", true, nil)
+	cdkClient.EXPECT().Deploy(gomock.Any(), cdkPath, []string{"Stack1", "Stack2"}, nil, cfg.Targets["develop"].Contexts).DoAndReturn(
+		func(ctx context.Context, _ string, _, _ []string, _ map[string]string) (string, error) {
+			<-ctx.Done()
+			return "failed!", errors.New("cdk deploy failed: Stack1: deploying...\n ✅  Stack1\nStack2: deploying...")
+		},
+	)
+	platformClient.EXPECT().AddLabel(ctx, constant.LabelDeployed).Return(nil)
+	platformClient.EXPECT().CreateComment(ctx, "### cdk deploy\n```\nfailed!\ncdk deploy failed: Stack1: deploying...\n ✅  Stack1\nStack2: deploying...\n```\n"+
+		"Canceled by sambaiz.\nFinished stacks: Stack1\n"+
+		"Not finished stacks: Stack2. The stack being deployed may continue to be updated by CloudFormation.\n"+
+		"Stacks: Stack1 Stack2 at headhash").Return(nil)
+
+	runner := &Runner{
+		platform:            platformClient,
+		git:                 gitClient,
+		config:              configClient,
+		cdk:                 cdkClient,
+		logger:              logger.MockLogger{},
+		cancelCheckInterval: time.Millisecond,
+	}
+	assert.Nil(t, runner.Deploy(ctx, "sambaiz", []string{"Stack1", "Stack2"}, nil, nil, nil))
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	tests := []struct {
+		title           string
+		userName        string
+		comments        []platform.Comment
+		expectedComment string
+	}{
+		{
+			title:    "canceled",
+			userName: "sambaiz",
+			comments: []platform.Comment{
+				{ID: 1, Body: "/deploy"},
+				{ID: 2, Body: "/cancel"},
+				{ID: 3, Body: "### cdk deploy\n```\nfailed!\n```\nCanceled by sambaiz.\nFinished stacks: none"},
+			},
+		},
+		{
+			title:    "not_running",
+			userName: "sambaiz",
+			comments: []platform.Comment{
+				{ID: 1, Body: "### cdk deploy\n```\nfailed!\n```\nCanceled by sambaiz.\nFinished stacks: none"},
+				{ID: 2, Body: "/cancel"},
+			},
+			expectedComment: "No running deploys to cancel. /cancel stops /deploy and /rollback running on this PR.",
+		},
+		{
+			title:           "user_is_not_allowed",
+			userName:        "foobar",
+			comments:        []platform.Comment{{ID: 1, Body: "/cancel"}},
+			expectedComment: "user foobar is not allowed to cancel",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+
+			platformClient.EXPECT().ListComments(ctx).Return(test.comments, nil)
+			if test.expectedComment != "" {
+				pr := &platform.PullRequest{BaseBranch: "develop", BaseCommitHash: "basehash"}
+				clonePath := (&Runner{}).clonePath(pr.BaseBranch)
+				platformClient.EXPECT().GetPullRequest(ctx).Return(pr, nil)
+				gitClient.EXPECT().Clone(clonePath, &pr.BaseCommitHash).Return(nil)
+				configClient.EXPECT().Read(fmt.Sprintf("%s/cdkbot.yml", clonePath)).Return(&config.Config{
+					DeployUsers: []string{"sambaiz"},
+				}, nil)
+				platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+			}
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Run(ctx, "/cancel", test.userName))
+		})
+	}
+}
@@ -9,10 +9,16 @@ import (
 	"github.com/sambaiz/cdkbot/tasks/operation/git"
 	"github.com/sambaiz/cdkbot/tasks/operation/logger"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/sambaiz/cdkbot/tasks/operation/policy"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
 	"go.uber.org/zap"
+	"net/url"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Runnerer is interface of Runner
@@ -37,7 +43,12 @@ type Runner struct {
 	git      git.Clienter
 	config   config.Readerer
 	cdk      cdk.Clienter
+	policy   policy.Loaderer
 	logger   logger.Loggerer
+	// directory name of the repository under cloneRoot
+	repoDir string
+	// how often running deploys check /cancel comments. Default is cancelCheckInterval.
+	cancelCheckInterval time.Duration
 }
 
 // NewRunner creates Runner
@@ -47,13 +58,19 @@ func NewRunner(client platform.Clienter, cloneURL string, logger logger.Loggerer
 		git:      git.NewClient(cloneURL),
 		config:   new(config.Reader),
 		cdk:      new(cdk.Client),
+		policy:   new(policy.Loader),
 		logger:   logger,
+		repoDir:  repoDirName(cloneURL),
 	}
 }
 
 type resultState struct {
 	state       constant.State
 	description string
+	// details reported to platforms which support them
+	output        string
+	changedStacks []string
+	cdkRoot       string
 }
 
 func newResultState(state constant.State, description string) *resultState {
@@ -63,11 +80,20 @@ func newResultState(state constant.State, description string) *resultState {
 	}
 }
 
+// withDetail sets the cdk output and stacks having differences
+func (s *resultState) withDetail(output string, changedStacks []string, cdkRoot string) *resultState {
+	s.output = output
+	s.changedStacks = changedStacks
+	s.cdkRoot = cdkRoot
+	return s
+}
+
 func (r *Runner) updateStatus(
 	ctx context.Context,
+	command string,
 	f func() (*resultState, error),
 ) error {
-	if err := r.platform.SetStatus(ctx, constant.StateRunning, ""); err != nil {
+	if err := r.setStatus(ctx, command, newResultState(constant.StateRunning, "")); err != nil {
 		return err
 	}
 	if err := r.platform.AddLabel(ctx, constant.LabelRunning); err != nil {
@@ -78,32 +104,115 @@ func (r *Runner) updateStatus(
 		r.logger.Error("remove label error", zap.Error(err))
 	}
 	if err != nil {
-		if err := r.platform.SetStatus(
+		if err := r.setStatus(
 			ctx,
-			constant.StateError,
-			err.Error(),
+			command,
+			newResultState(constant.StateError, err.Error()),
 		); err != nil {
 			r.logger.Error("set status error", zap.Error(err))
 		}
 		return err
 	}
-	if err := r.platform.SetStatus(
-		ctx,
-		state.state,
-		state.description,
-	); err != nil {
+	if err := r.setStatus(ctx, command, state); err != nil {
 		return err
 	}
 	return nil
 }
 
-const clonePath = "/tmp/repo"
+// handleRedelivery gives up the job which failed too many times and notifies the previous run interrupted.
+// It returns true if the job should not be run.
+func (r *Runner) handleRedelivery(ctx context.Context, command string) (bool, error) {
+	if queue.GivenUp(ctx) {
+		return true, r.giveUp(ctx, command)
+	}
+	if queue.Redelivered(ctx) {
+		if err := r.notifyInterrupted(ctx, command); err != nil {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// notifyInterrupted removes the running label left by the previous run which was interrupted and comments it
+func (r *Runner) notifyInterrupted(ctx context.Context, command string) error {
+	pr, err := r.platform.GetPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := pr.Labels[constant.LabelRunning.Name]; !ok {
+		return nil
+	}
+	if err := r.platform.RemoveLabel(ctx, constant.LabelRunning); err != nil {
+		return err
+	}
+	return r.platform.CreateComment(
+		ctx,
+		fmt.Sprintf("The previous %s was interrupted halfway. Retrying it.", command),
+	)
+}
+
+// giveUp cleans up the job which failed too many times instead of running it.
+// The running label and the running status are left if the last run crashed.
+func (r *Runner) giveUp(ctx context.Context, command string) error {
+	pr, err := r.platform.GetPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := pr.Labels[constant.LabelRunning.Name]; ok {
+		if err := r.platform.RemoveLabel(ctx, constant.LabelRunning); err != nil {
+			return err
+		}
+		if err := r.setStatus(
+			ctx,
+			command,
+			newResultState(constant.StateError, fmt.Sprintf("Failed %d times and given up", queue.MaxReceiveCount)),
+		); err != nil {
+			return err
+		}
+	}
+	return r.platform.CreateComment(
+		ctx,
+		fmt.Sprintf("The %s failed %d times, so it was given up. Run it again after fixing the cause.", command, queue.MaxReceiveCount),
+	)
+}
+
+func (r *Runner) setStatus(ctx context.Context, command string, state *resultState) error {
+	if reporter, ok := r.platform.(platform.ResultReporter); ok {
+		return reporter.ReportResult(ctx, platform.Result{
+			Command:       command,
+			State:         state.state,
+			Description:   state.description,
+			Output:        state.output,
+			ChangedStacks: state.changedStacks,
+			CDKRoot:       state.cdkRoot,
+		})
+	}
+	return r.platform.SetStatus(ctx, state.state, state.description)
+}
+
+const cloneRoot = "/tmp/repo"
+
+// repoDirName returns the escaped path of the repository such as "owner%2Frepo"
+func repoDirName(cloneURL string) string {
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return ""
+	}
+	return url.PathEscape(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"))
+}
+
+// clonePath returns the directory where the repository is cloned for the base branch.
+// Jobs of the same repository and base branch are not run concurrently, so they can share it.
+func (r *Runner) clonePath(baseBranch string) string {
+	return filepath.Join(cloneRoot, r.repoDir, url.PathEscape(baseBranch))
+}
 
 func (r *Runner) setup(ctx context.Context, cloneHead bool) (string, *config.Config, *config.Target, *platform.PullRequest, error) {
 	pr, err := r.platform.GetPullRequest(ctx)
 	if err != nil {
 		return "", nil, nil, nil, err
 	}
+	clonePath := r.clonePath(pr.BaseBranch)
 	if cloneHead {
 		if err := r.git.Clone(clonePath, &pr.HeadCommitHash); err != nil {
 			return "", nil, nil, nil, err
@@ -119,6 +228,10 @@ func (r *Runner) setup(ctx context.Context, cloneHead bool) (string, *config.Con
 			return "", nil, nil, nil, err
 		}
 	}
+	// cdkbot.yml of the PR is not trusted because it has users and stacks allowed to deploy or destroy
+	if err := r.git.CheckoutFile(clonePath, "cdkbot.yml", pr.BaseBranch); err != nil {
+		return "", nil, nil, nil, err
+	}
 	cfg, err := r.config.Read(fmt.Sprintf("%s/cdkbot.yml", clonePath))
 	if err != nil {
 		return "", nil, nil, nil, err
@@ -129,21 +242,23 @@ func (r *Runner) setup(ctx context.Context, cloneHead bool) (string, *config.Con
 		return cdkPath, cfg, nil, nil, nil
 	}
 
-	// override cdkbot.yml & cdk.yml of base branch
-	if err := r.git.CheckoutFile(clonePath, "cdkbot.yml", pr.BaseBranch); err != nil {
-		return "", nil, nil, nil, err
-	}
+	// override cdk.json & policies of base branch
 	if err := r.git.CheckoutFile(fmt.Sprintf("%s/%s", clonePath, cfg.CDKRoot), "cdk.json", pr.BaseBranch); err != nil {
 		return "", nil, nil, nil, err
 	}
+	if cfg.PolicyDir != "" {
+		if err := r.git.CheckoutFile(clonePath, cfg.PolicyDir, pr.BaseBranch); err != nil {
+			return "", nil, nil, nil, err
+		}
+	}
 
-	if err := r.cdk.Setup(cdkPath); err != nil {
+	if err := r.cdk.Setup(ctx, cdkPath); err != nil {
 		return "", nil, nil, nil, err
 	}
 
 	for _, preCommand := range cfg.PreCommands {
 		command := strings.Split(preCommand, " ")
-		cmd := exec.Command(command[0], command[1:]...)
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
 		cmd.Dir = cdkPath
 		if out, err := cmd.CombinedOutput(); err != nil || cmd.ProcessState.ExitCode() != 0 {
 			return "", nil, nil, nil, fmt.Errorf("preCommand %s failed: %s %v", preCommand, string(out), err)
@@ -152,34 +267,99 @@ func (r *Runner) setup(ctx context.Context, cloneHead bool) (string, *config.Con
 	return cdkPath, cfg, &target, pr, nil
 }
 
-// Run a command
+// readBaseConfig reads cdkbot.yml of the base branch without setting up cdk
+func (r *Runner) readBaseConfig(ctx context.Context) (*config.Config, *platform.PullRequest, error) {
+	pr, err := r.platform.GetPullRequest(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	clonePath := r.clonePath(pr.BaseBranch)
+	if err := r.git.Clone(clonePath, &pr.BaseCommitHash); err != nil {
+		return nil, nil, err
+	}
+	cfg, err := r.config.Read(fmt.Sprintf("%s/cdkbot.yml", clonePath))
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, pr, nil
+}
+
+// Run a command.
+// Errors of invalid arguments are commented and returned as permanent because they never succeed by retrying.
+// The name of the command is matched exactly with the first field, so "/deployed" is not regarded as /deploy.
 func (r *Runner) Run(ctx context.Context, command string, userName string) error {
-	if command == "/diff" {
-		return r.Diff(ctx)
-	} else if strings.HasPrefix(command, "/deploy") {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return nil
+	}
+	if done, err := r.handleRedelivery(ctx, strings.TrimPrefix(fields[0], "/")); done || err != nil {
+		return err
+	}
+	switch fields[0] {
+	case "/diff":
 		stacks, err := parseStacks(command)
 		if err != nil {
-			return err
+			return r.rejectInvalidArguments(ctx, err)
+		}
+		return r.diff(ctx, stacks)
+	case "/deploy":
+		args, err := parseCommandArgs(command, deployFlags)
+		if err != nil {
+			return r.rejectInvalidArguments(ctx, err)
 		}
-		return r.Deploy(ctx, userName, stacks)
-	} else if strings.HasPrefix(command, "/rollback") {
+		return r.Deploy(ctx, userName, args.stacks, args.flags, args.contexts, args.allowReplace)
+	case "/rollback":
 		stacks, err := parseStacks(command)
 		if err != nil {
-			return err
+			return r.rejectInvalidArguments(ctx, err)
 		}
 		return r.Rollback(ctx, userName, stacks)
+	case "/destroy":
+		stacks, confirmHash, err := parseDestroyCommand(command)
+		if err != nil {
+			return r.rejectInvalidArguments(ctx, err)
+		}
+		return r.Destroy(ctx, userName, stacks, confirmHash)
+	case "/synth":
+		stacks, err := parseStacks(command)
+		if err != nil {
+			return r.rejectInvalidArguments(ctx, err)
+		}
+		return r.Synth(ctx, stacks)
+	case "/status":
+		return r.Status(ctx)
+	case "/cancel":
+		return r.Cancel(ctx, userName)
+	case "/unlock":
+		return r.Unlock(ctx, userName)
+	case "/help":
+		return r.Help(ctx)
+	}
+	if match := unknownCommandFormat.FindStringSubmatch(command); match != nil {
+		return r.replyUnknownCommand(ctx, match[1])
 	}
-
 	return nil
 }
 
+// rejectInvalidArguments comments the error of the arguments so that the user can fix them
+func (r *Runner) rejectInvalidArguments(ctx context.Context, err error) error {
+	if err := r.platform.CreateComment(ctx, fmt.Sprintf("%s. Run `/help` to see the usage.", err.Error())); err != nil {
+		return err
+	}
+	return queue.Permanent(err)
+}
+
+// A comment starting with it is regarded as a command such as "/deplyo".
+// It is followed by a space or the end so as not to reply to a path such as "/usr/bin".
+var unknownCommandFormat = regexp.MustCompile(`^(/[a-zA-Z][a-zA-Z\d\-_]*)(?:\s|$)`)
+
 func parseStacks(command string) ([]string, error) {
-	args := strings.Split(command, " ")
+	args := strings.Fields(command)
 	stacks := []string{}
 	if len(args) != 0 {
 		stacks = args[1:]
 		for _, stack := range stacks {
-			if err := validateStackName(stack); err != nil {
+			if err := validateStackPattern(stack); err != nil {
 				return nil, err
 			}
 		}
@@ -187,6 +367,98 @@ func parseStacks(command string) ([]string, error) {
 	return stacks, nil
 }
 
+// cdk flags allowed in /deploy
+var deployFlags = []string{"--exclusively", "--hotswap", "--hotswap-fallback"}
+
+// Flag of cdkbot to acknowledge replacement or deletion of a stateful resource such as "--allow-replace=Table1234"
+const allowReplacePrefix = "--allow-replace="
+
+// Logical ID of CloudFormation resources
+var validLogicalIDFormat = regexp.MustCompile(`^[a-zA-Z\d]{1,255}$`)
+
+// commandArgs are the arguments of a command such as "/deploy --exclusively -c key=value Stack1"
+type commandArgs struct {
+	stacks   []string
+	flags    []string
+	contexts map[string]string
+	// Logical IDs of stateful resources allowed to be replaced or deleted by "--allow-replace=LogicalId"
+	allowReplace []string
+}
+
+// parseCommandArgs parses stacks, flags in allowedFlags, contexts given by "-c key=value" or "--context key=value"
+// and logical IDs given by "--allow-replace=LogicalId"
+func parseCommandArgs(command string, allowedFlags []string) (*commandArgs, error) {
+	fields := strings.Fields(command)
+	args := &commandArgs{
+		stacks:       []string{},
+		flags:        []string{},
+		contexts:     map[string]string{},
+		allowReplace: []string{},
+	}
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "-c" || field == "--context":
+			i++
+			if i == len(fields) {
+				return nil, fmt.Errorf("%s needs key=value", field)
+			}
+			key, value, err := parseContext(fields[i])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := args.contexts[key]; ok {
+				return nil, fmt.Errorf("Context %s is specified more than once", key)
+			}
+			args.contexts[key] = value
+		case strings.HasPrefix(field, allowReplacePrefix):
+			logicalID := strings.TrimPrefix(field, allowReplacePrefix)
+			if !validLogicalIDFormat.MatchString(logicalID) {
+				return nil, fmt.Errorf("Invalid logical ID %s", logicalID)
+			}
+			if !slices.Contains(args.allowReplace, logicalID) {
+				args.allowReplace = append(args.allowReplace, logicalID)
+			}
+		case strings.HasPrefix(field, "-"):
+			if !slices.Contains(allowedFlags, field) {
+				return nil, fmt.Errorf("Flag %s is not allowed", field)
+			}
+			if slices.Contains(args.flags, field) {
+				return nil, fmt.Errorf("Flag %s is specified more than once", field)
+			}
+			args.flags = append(args.flags, field)
+		default:
+			if err := validateStackPattern(field); err != nil {
+				return nil, err
+			}
+			args.stacks = append(args.stacks, field)
+		}
+	}
+	return args, nil
+}
+
+var (
+	// Key of context such as "env" and "@aws-cdk/core:newStyleStackSynthesis"
+	validContextKeyFormat = regexp.MustCompile(`^[a-zA-Z@][a-zA-Z\d\-_:./@]{0,127}$`)
+	// Value of context not to contain illegal characters
+	validContextValueFormat = regexp.MustCompile(`^[a-zA-Z\d\-_:./@,+]{0,256}$`)
+)
+
+// parseContext parses "key=value"
+func parseContext(arg string) (string, string, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return "", "", fmt.Errorf("Invalid context %s. Specify it as key=value", arg)
+	}
+	if !validContextKeyFormat.MatchString(key) {
+		return "", "", fmt.Errorf("Invalid context key %s", key)
+	}
+	if !validContextValueFormat.MatchString(value) {
+		return "", "", fmt.Errorf("Invalid context value %s", value)
+	}
+	return key, value, nil
+}
+
 // It must start with an alphabetic character and can't be longer than 128 characters.
 // A stack name can contain only alphanumeric characters (case-sensitive) and hyphens.
 var validStackNameFormat = regexp.MustCompile(`^[a-zA-Z][a-zA-Z\d\-]{0,127}$`)
@@ -198,3 +470,25 @@ func validateStackName(name string) error {
 	}
 	return nil
 }
+
+// A stack pattern is a stack name or a path of a stack in stages such as "Stage/Stack",
+// and each name can contain the wildcards "*" and "?" such as "Prod-*".
+var validStackPatternFormat = regexp.MustCompile(`^[a-zA-Z*?][a-zA-Z\d\-*?]{0,127}(/[a-zA-Z*?][a-zA-Z\d\-*?]{0,127})*$`)
+
+// Check the stack pattern so that the command does not contain illegal characters.
+func validateStackPattern(pattern string) error {
+	if len(pattern) > 256 || !validStackPatternFormat.MatchString(pattern) {
+		return fmt.Errorf("Invalid stack name %s", pattern)
+	}
+	return nil
+}
+
+// hasStackWildcard returns whether any of the stacks contains wildcards
+func hasStackWildcard(stacks []string) bool {
+	for _, stack := range stacks {
+		if strings.ContainsAny(stack, "*?") {
+			return true
+		}
+	}
+	return false
+}
@@ -3,23 +3,33 @@ package command
 import (
 	"context"
 	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
 	"github.com/sambaiz/cdkbot/tasks/operation/constant"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"slices"
+	"sort"
+	"strings"
 )
 
-// Deploy runs cdk deploy
+// Deploy runs cdk deploy with flags and contexts overriding the ones of the target.
+// It is blocked if the head commit doesn't have requiredApprovals of the target, stateful resources are replaced or deleted and their logical IDs are not in allowReplace,
+// or policies are violated.
 func (r *Runner) Deploy(
 	ctx context.Context,
 	userName string,
 	stacks []string,
+	flags []string,
+	contexts map[string]string,
+	allowReplace []string,
 ) error {
 	if has, _ := r.hasOutdatedDiffLabel(ctx); has {
 		if err := r.platform.CreateComment(ctx, "Differences are outdated. Run /diff instead."); err != nil {
 			return err
 		}
-		return r.Diff(ctx)
+		return r.diff(ctx, nil)
 	}
-	return r.updateStatus(ctx, func() (*resultState, error) {
+	return r.updateStatus(ctx, "deploy", func() (*resultState, error) {
 		cdkPath, cfg, target, pr, err := r.setup(ctx, true)
 		if err != nil {
 			return nil, err
@@ -30,6 +40,25 @@ func (r *Runner) Deploy(
 		if !cfg.IsUserAllowedDeploy(userName) {
 			return newResultState(constant.StateNotMergeReady, fmt.Sprintf("user %s is not allowed to deploy", userName)), nil
 		}
+		if key, ok := notOverridableContext(target, contexts); !ok {
+			return newResultState(
+				constant.StateNotMergeReady,
+				fmt.Sprintf("context %s is not overridable. Add it to overridableContexts of the target.", key),
+			), nil
+		}
+		if target.RequiredApprovals > 0 {
+			reviews, err := r.platform.ListReviews(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if approvals := approvalsOnHead(reviews, pr); approvals < target.RequiredApprovals {
+				return newResultState(
+					constant.StateNotMergeReady,
+					fmt.Sprintf("%d/%d approvals on the head commit. Get approvals before /deploy", approvals, target.RequiredApprovals),
+				), nil
+			}
+		}
+		deployContexts := target.OverriddenContexts(contexts)
 		openPRs, err := r.platform.GetOpenPullRequests(ctx)
 		if err != nil {
 			return nil, err
@@ -40,21 +69,50 @@ func (r *Runner) Deploy(
 				fmt.Sprintf("deployed PR #%d is still opened. First /deploy and merge it, or /rollback.", number),
 			), nil
 		}
-		if len(stacks) == 0 {
-			stacks, err = r.cdk.List(cdkPath, target.Contexts)
-			if err != nil {
-				return nil, err
-			}
+		var unmatched []string
+		stacks, unmatched, err = r.resolveStacks(ctx, cdkPath, stacks, deployContexts)
+		if err != nil {
+			return nil, err
+		}
+		if len(unmatched) != 0 {
+			return newResultState(constant.StateNotMergeReady, fmt.Sprintf("No stacks match %s", strings.Join(unmatched, " "))), nil
+		}
+		blocked, err := r.checkBeforeDeploy(ctx, cdkPath, cfg, target, pr, stacks, flags, deployContexts, allowReplace)
+		if err != nil {
+			return nil, err
+		}
+		if blocked != nil {
+			return blocked, nil
 		}
 		var (
 			errMessage string
+			diff       string
 			hasDiff    bool
 		)
-		result, deployErr := r.cdk.Deploy(cdkPath, stacks, target.Contexts)
+		deployCtx, stopWatchingCancel, err := r.watchCancel(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		result, deployErr := r.cdk.Deploy(deployCtx, cdkPath, stacks, flags, deployContexts)
+		// cdk may finish just before it is canceled
+		if canceledBy := stopWatchingCancel(); canceledBy != "" && deployErr != nil {
+			if err := r.platform.AddLabel(ctx, constant.LabelDeployed); err != nil {
+				return nil, err
+			}
+			output := fmt.Sprintf("%s\n%s", result, deployErr.Error())
+			if err := r.platform.CreateComment(
+				ctx,
+				fmt.Sprintf("### cdk deploy\n```\n%s\n```\n%s\n%s", output, cancelReport(canceledBy, stacks, output), stacksRecord(stacks, pr.HeadCommitHash)),
+			); err != nil {
+				return nil, err
+			}
+			return newResultState(constant.StateNotMergeReady, fmt.Sprintf("Canceled by %s", canceledBy)).withDetail(output, nil, cfg.CDKRoot), nil
+		}
 		if deployErr != nil {
 			errMessage = deployErr.Error()
 		} else {
-			_, hasDiff, err = r.cdk.Diff(cdkPath, nil, target.Contexts)
+			// checked with contexts of the target so as not to merge by the diff of overridden ones
+			diff, hasDiff, err = r.cdk.Diff(ctx, cdkPath, nil, target.Contexts)
 			if err != nil {
 				errMessage = err.Error()
 			}
@@ -64,12 +122,13 @@ func (r *Runner) Deploy(
 		}
 		if err := r.platform.CreateComment(
 			ctx,
-			fmt.Sprintf("### cdk deploy\n```\n%s\n%s\n```", result, errMessage),
+			fmt.Sprintf("### cdk deploy\n```\n%s\n%s\n```\n%s", result, errMessage, stacksRecord(stacks, pr.HeadCommitHash)),
 		); err != nil {
 			return nil, err
 		}
+		output := fmt.Sprintf("%s\n%s", result, errMessage)
 		if errMessage != "" {
-			return newResultState(constant.StateNotMergeReady, "Fix codes"), nil
+			return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(output, nil, cfg.CDKRoot), nil
 		}
 		if !hasDiff {
 			if err := r.platform.MergePullRequest(ctx, "automatically merged by cdkbot"); err != nil {
@@ -89,12 +148,62 @@ func (r *Runner) Deploy(
 					}
 				}
 			}
-			return newResultState(constant.StateMergeReady, "No diffs. Let's merge!"), nil
+			return newResultState(constant.StateMergeReady, "No diffs. Let's merge!").withDetail(output, nil, cfg.CDKRoot), nil
 		}
-		return newResultState(constant.StateNotMergeReady, "Go ahead with deploy."), nil
+		return newResultState(constant.StateNotMergeReady, "Go ahead with deploy.").withDetail(output, changedStacks(diff), cfg.CDKRoot), nil
 	})
 }
 
+// checkBeforeDeploy runs cdk diff of the stacks to be deployed and returns the state to block deploying
+// if stateful resources are replaced or deleted without acknowledgement by allowReplace or the label,
+// or policies are violated. It returns nil if deploying can go ahead.
+// The stacks which the stacks depend on are checked too because cdk deploy deploys them unless --exclusively is specified.
+func (r *Runner) checkBeforeDeploy(
+	ctx context.Context,
+	cdkPath string,
+	cfg *config.Config,
+	target *config.Target,
+	pr *platform.PullRequest,
+	stacks []string,
+	flags []string,
+	contexts map[string]string,
+	allowReplace []string,
+) (*resultState, error) {
+	_, acknowledged := pr.Labels[constant.LabelAllowReplace.Name]
+	if acknowledged && cfg.PolicyDir == "" {
+		return nil, nil
+	}
+	if !slices.Contains(flags, "--exclusively") {
+		var err error
+		stacks, err = r.cdk.Dependencies(ctx, cdkPath, stacks, contexts)
+		if err != nil {
+			return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(err.Error(), nil, cfg.CDKRoot), nil
+		}
+	}
+	diff, _, err := r.cdk.Diff(ctx, cdkPath, stacks, contexts)
+	if err != nil {
+		return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(err.Error(), nil, cfg.CDKRoot), nil
+	}
+	if !acknowledged {
+		if replaced := replacedStatefulResources(cdk.ParseDiff(diff), target, allowReplace); len(replaced) != 0 {
+			if err := r.platform.CreateComment(ctx, blockedDeployComment(replaced)); err != nil {
+				return nil, err
+			}
+			return newResultState(
+				constant.StateNotMergeReady,
+				"Replacement or deletion of stateful resources is not acknowledged",
+			).withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
+		}
+	}
+	if policies := r.checkPolicies(ctx, cdkPath, cfg, pr, stacks, contexts, diff); policies.blocked() {
+		if err := r.platform.CreateComment(ctx, blockedDeployHeader+policies.report()); err != nil {
+			return nil, err
+		}
+		return newResultState(constant.StateNotMergeReady, policies.description()).withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
+	}
+	return nil, nil
+}
+
 func (r *Runner) hasOutdatedDiffLabel(ctx context.Context) (bool, error) {
 	// get labels from not event but API because to get latest data.
 	pr, err := r.platform.GetPullRequest(ctx)
@@ -107,6 +216,21 @@ func (r *Runner) hasOutdatedDiffLabel(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
+// notOverridableContext returns the first key of contexts which the target doesn't allow to override
+func notOverridableContext(target *config.Target, contexts map[string]string) (string, bool) {
+	keys := []string{}
+	for key := range contexts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !target.IsContextOverridable(key) {
+			return key, false
+		}
+	}
+	return "", true
+}
+
 func existsOtherDeployedSameBasePRs(openPRs []platform.PullRequest, pr *platform.PullRequest) (int, bool) {
 	for _, openPR := range openPRs {
 		if openPR.Number == pr.Number || openPR.BaseBranch != pr.BaseBranch {
@@ -118,3 +242,35 @@ func existsOtherDeployedSameBasePRs(openPRs []platform.PullRequest, pr *platform
 	}
 	return 0, false
 }
+
+// resolveStacks returns all stacks if stacks are not specified, or expands the wildcards with the listed stacks
+// and comments the resolved stacks before deploying them. The patterns matching no stacks are also returned.
+func (r *Runner) resolveStacks(
+	ctx context.Context,
+	cdkPath string,
+	stacks []string,
+	contexts map[string]string,
+) ([]string, []string, error) {
+	if len(stacks) != 0 && !hasStackWildcard(stacks) {
+		return stacks, nil, nil
+	}
+	listed, err := r.cdk.List(ctx, cdkPath, contexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(stacks) == 0 {
+		return listed, nil, nil
+	}
+	resolved, unmatched := cdk.MatchStacks(stacks, listed)
+	if len(unmatched) != 0 {
+		return nil, unmatched, nil
+	}
+	lines := []string{fmt.Sprintf("Resolved `%s` to the following stacks:", strings.Join(stacks, " "))}
+	for _, stack := range resolved {
+		lines = append(lines, fmt.Sprintf("- %s", stack))
+	}
+	if err := r.platform.CreateComment(ctx, strings.Join(lines, "\n")); err != nil {
+		return nil, nil, err
+	}
+	return resolved, nil, nil
+}
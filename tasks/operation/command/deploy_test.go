@@ -11,11 +11,12 @@ import (
 	"github.com/sambaiz/cdkbot/tasks/operation/logger"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform"
 	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"strings"
 	"testing"
 
 	"errors"
-	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 func TestRunner_Deploy(t *testing.T) {
@@ -25,11 +26,18 @@ func TestRunner_Deploy(t *testing.T) {
 		isError  bool
 	}
 	type test struct {
-		title         string
-		inUserName    string
-		inStacks      []string
-		cfg           config.Config
-		baseBranch    string
+		title          string
+		inUserName     string
+		inStacks       []string
+		inFlags        []string
+		inContexts     map[string]string
+		inAllowReplace []string
+		cfg            config.Config
+		baseBranch     string
+		labels         map[string]constant.Label
+		reviews        []platform.Review
+		// output of cdk diff before deploying
+		preDiff       string
 		deployError   error
 		resultHasDiff bool
 		diffError     error
@@ -48,7 +56,7 @@ func TestRunner_Deploy(t *testing.T) {
 			},
 			baseBranch: "develop",
 			expected: expected{
-				comment:  "### cdk deploy\n```\nresult\n\n```",
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 Stack2 at headhash",
 				outState: newResultState(constant.StateMergeReady, "No targets are matched"),
 				isError:  false,
 			},
@@ -70,7 +78,7 @@ func TestRunner_Deploy(t *testing.T) {
 			baseBranch:    "develop",
 			resultHasDiff: false,
 			expected: expected{
-				comment:  "### cdk deploy\n```\nresult\n\n```",
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 Stack2 at headhash",
 				outState: newResultState(constant.StateMergeReady, "No diffs. Let's merge!"),
 				isError:  false,
 			},
@@ -88,11 +96,113 @@ func TestRunner_Deploy(t *testing.T) {
 			baseBranch:    "develop",
 			resultHasDiff: true,
 			expected: expected{
-				comment:  "### cdk deploy\n```\nresult\n\n```",
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 Stack2 at headhash",
 				outState: newResultState(constant.StateNotMergeReady, "Go ahead with deploy."),
 				isError:  false,
 			},
 		},
+		{
+			title:      "with_flags_and_contexts",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			inFlags:    []string{"--hotswap"},
+			inContexts: map[string]string{"version": "2"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts:            map[string]string{"env": "stg", "version": "1"},
+						OverridableContexts: []string{"version"},
+					},
+				},
+			},
+			baseBranch:    "develop",
+			resultHasDiff: true,
+			expected: expected{
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 at headhash",
+				outState: newResultState(constant.StateNotMergeReady, "Go ahead with deploy."),
+				isError:  false,
+			},
+		},
+		{
+			title:      "stateful_resource_is_replaced",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {},
+				},
+			},
+			baseBranch: "develop",
+			preDiff:    "Stack Stack1\nResources\n[~] AWS::DynamoDB::Table Table TableCD117FA1 replace\n[-] AWS::S3::Bucket Logs LogsBucketA1B2 orphan\n[-] AWS::SNS::Topic Topic Topic2A4C2B8F destroy",
+			expected: expected{
+				comment: "### cdk deploy (blocked)\nReplacement or deletion of the following stateful resources is not acknowledged.\n\n" +
+					"| Stack | Logical ID | Type | Change |\n| --- | --- | --- | --- |\n| Stack1 | TableCD117FA1 | AWS::DynamoDB::Table | replace |\n\n" +
+					"To deploy them anyway, run /deploy with `--allow-replace=TableCD117FA1` or add the `cdkbot:allow replace` label to the PR.",
+				outState: newResultState(constant.StateNotMergeReady, "Replacement or deletion of stateful resources is not acknowledged"),
+				isError:  false,
+			},
+		},
+		{
+			title:          "replacement_is_acknowledged",
+			inUserName:     "sambaiz",
+			inStacks:       []string{"Stack1"},
+			inAllowReplace: []string{"TableCD117FA1"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {},
+				},
+			},
+			baseBranch:    "develop",
+			preDiff:       "Stack Stack1\nResources\n[~] AWS::DynamoDB::Table Table TableCD117FA1 replace",
+			resultHasDiff: true,
+			expected: expected{
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 at headhash",
+				outState: newResultState(constant.StateNotMergeReady, "Go ahead with deploy."),
+				isError:  false,
+			},
+		},
+		{
+			title:      "replacement_is_acknowledged_by_label",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {},
+				},
+			},
+			baseBranch:    "develop",
+			labels:        map[string]constant.Label{constant.LabelAllowReplace.Name: constant.LabelAllowReplace},
+			resultHasDiff: true,
+			expected: expected{
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 at headhash",
+				outState: newResultState(constant.StateNotMergeReady, "Go ahead with deploy."),
+				isError:  false,
+			},
+		},
+		{
+			title:      "context_is_not_overridable",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			inContexts: map[string]string{"version": "2", "env": "prd"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts:            map[string]string{"env": "stg", "version": "1"},
+						OverridableContexts: []string{"version"},
+					},
+				},
+			},
+			baseBranch: "develop",
+			expected: expected{
+				outState: newResultState(constant.StateNotMergeReady, "context env is not overridable. Add it to overridableContexts of the target."),
+				isError:  false,
+			},
+		},
 		{
 			title:      "cdk_deploy_error",
 			inUserName: "sambaiz",
@@ -106,7 +216,7 @@ func TestRunner_Deploy(t *testing.T) {
 			baseBranch:  "develop",
 			deployError: errors.New("cdk deploy error"),
 			expected: expected{
-				comment:  "### cdk deploy\n```\nresult\ncdk deploy error\n```",
+				comment:  "### cdk deploy\n```\nresult\ncdk deploy error\n```\nStacks: Stack1 Stack2 at headhash",
 				outState: newResultState(constant.StateNotMergeReady, "Fix codes"),
 				isError:  false,
 			},
@@ -124,7 +234,7 @@ func TestRunner_Deploy(t *testing.T) {
 			baseBranch: "develop",
 			diffError:  errors.New("cdk diff error"),
 			expected: expected{
-				comment:  "### cdk deploy\n```\nresult\ncdk diff error\n```",
+				comment:  "### cdk deploy\n```\nresult\ncdk diff error\n```\nStacks: Stack1 Stack2 at headhash",
 				outState: newResultState(constant.StateNotMergeReady, "Fix codes"),
 				isError:  false,
 			},
@@ -163,6 +273,48 @@ func TestRunner_Deploy(t *testing.T) {
 				isError:  false,
 			},
 		},
+		{
+			title:      "not_enough_approvals_on_head",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"master": {RequiredApprovals: 2},
+				},
+			},
+			baseBranch: "master",
+			reviews: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "headhash"},
+				{User: "bob", Approved: true, CommitHash: "oldhash"},
+			},
+			expected: expected{
+				outState: newResultState(constant.StateNotMergeReady, "1/2 approvals on the head commit. Get approvals before /deploy"),
+				isError:  false,
+			},
+		},
+		{
+			title:      "approved_on_head",
+			inUserName: "sambaiz",
+			inStacks:   []string{"Stack1"},
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"master": {RequiredApprovals: 2},
+				},
+			},
+			baseBranch: "master",
+			reviews: []platform.Review{
+				{User: "alice", Approved: true, CommitHash: "headhash"},
+				{User: "bob", Approved: true, CommitHash: "headhash"},
+			},
+			resultHasDiff: true,
+			expected: expected{
+				comment:  "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 at headhash",
+				outState: newResultState(constant.StateNotMergeReady, "Go ahead with deploy."),
+				isError:  false,
+			},
+		},
 	}
 
 	constructRunnerWithMock := func(
@@ -197,10 +349,12 @@ func TestRunner_Deploy(t *testing.T) {
 				BaseBranch:     test.baseBranch,
 				BaseCommitHash: "basehash",
 				HeadCommitHash: "headhash",
+				Labels:         test.labels,
+				Author:         "author",
 			},
 		)
 		target, ok := test.cfg.Targets[test.baseBranch]
-		if !ok || !test.cfg.IsUserAllowedDeploy(test.inUserName) {
+		if _, overridable := notOverridableContext(&target, test.inContexts); !ok || !test.cfg.IsUserAllowedDeploy(test.inUserName) || !overridable {
 			return &Runner{
 				platform: platformClient,
 				git:      gitClient,
@@ -210,6 +364,19 @@ func TestRunner_Deploy(t *testing.T) {
 			}
 		}
 
+		if target.RequiredApprovals > 0 {
+			platformClient.EXPECT().ListReviews(ctx).Return(test.reviews, nil)
+			if approvalsOnHead(test.reviews, &platform.PullRequest{Author: "author", HeadCommitHash: "headhash"}) < target.RequiredApprovals {
+				return &Runner{
+					platform: platformClient,
+					git:      gitClient,
+					config:   configClient,
+					cdk:      cdkClient,
+					logger:   logger.MockLogger{},
+				}
+			}
+		}
+
 		openPRs := []platform.PullRequest{
 			{
 				Number:     1,
@@ -240,15 +407,31 @@ func TestRunner_Deploy(t *testing.T) {
 			}
 		}
 
-		cdkPath := fmt.Sprintf("%s/%s", clonePath, test.cfg.CDKRoot)
+		cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath(test.baseBranch), test.cfg.CDKRoot)
 		if len(test.inStacks) == 0 {
 			test.inStacks = []string{"Stack1", "Stack2"}
-			cdkClient.EXPECT().List(cdkPath, target.Contexts).Return(test.inStacks, nil)
+			cdkClient.EXPECT().List(ctx, cdkPath, target.OverriddenContexts(test.inContexts)).Return(test.inStacks, nil)
+		}
+		if _, ok := test.labels[constant.LabelAllowReplace.Name]; !ok {
+			cdkClient.EXPECT().Dependencies(ctx, cdkPath, test.inStacks, target.OverriddenContexts(test.inContexts)).Return(test.inStacks, nil)
+			cdkClient.EXPECT().Diff(ctx, cdkPath, test.inStacks, target.OverriddenContexts(test.inContexts)).Return(test.preDiff, true, nil)
+		}
+		if test.expected.outState.description == "Replacement or deletion of stateful resources is not acknowledged" {
+			platformClient.EXPECT().CreateComment(ctx, test.expected.comment)
+			return &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
 		}
 		result := "result"
-		cdkClient.EXPECT().Deploy(cdkPath, test.inStacks, target.Contexts).Return(result, test.deployError)
+		platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
+		// ctx which is canceled by /cancel is passed
+		cdkClient.EXPECT().Deploy(gomock.Any(), cdkPath, test.inStacks, test.inFlags, target.OverriddenContexts(test.inContexts)).Return(result, test.deployError)
 		if test.deployError == nil {
-			cdkClient.EXPECT().Diff(cdkPath, nil, target.Contexts).Return("", test.resultHasDiff, test.diffError)
+			cdkClient.EXPECT().Diff(ctx, cdkPath, nil, target.Contexts).Return("", test.resultHasDiff, test.diffError)
 		}
 
 		platformClient.EXPECT().AddLabel(ctx, constant.LabelDeployed).Return(nil)
@@ -286,7 +469,7 @@ func TestRunner_Deploy(t *testing.T) {
 			defer ctrl.Finish()
 			runner := constructRunnerWithMock(ctx, ctrl, test)
 			assert.Equal(t, test.expected.isError,
-				runner.Deploy(ctx, test.inUserName, test.inStacks) != nil,
+				runner.Deploy(ctx, test.inUserName, test.inStacks, test.inFlags, test.inContexts, test.inAllowReplace) != nil,
 			)
 		})
 	}
@@ -398,3 +581,139 @@ func TestExistsOtherDeployedSameBasePRs(t *testing.T) {
 		})
 	}
 }
+
+func TestRunner_resolveStacks(t *testing.T) {
+	listed := []string{"Dev-Api", "Prod-Api", "Prod-Web", "Prod/Db"}
+	tests := []struct {
+		title        string
+		inStacks     []string
+		expectList   bool
+		comment      string
+		outStacks    []string
+		outUnmatched []string
+	}{
+		{
+			title:     "names",
+			inStacks:  []string{"Prod-Web", "Stage/Stack"},
+			outStacks: []string{"Prod-Web", "Stage/Stack"},
+		},
+		{
+			title:      "no_stacks",
+			inStacks:   []string{},
+			expectList: true,
+			outStacks:  listed,
+		},
+		{
+			title:      "wildcards",
+			inStacks:   []string{"Prod-*", "Prod/*", "Prod-Api"},
+			expectList: true,
+			comment:    "Resolved `Prod-* Prod/* Prod-Api` to the following stacks:\n- Prod-Api\n- Prod-Web\n- Prod/Db",
+			outStacks:  []string{"Prod-Api", "Prod-Web", "Prod/Db"},
+		},
+		{
+			title:        "unmatched",
+			inStacks:     []string{"Prod-*", "Stg-*"},
+			expectList:   true,
+			outUnmatched: []string{"Stg-*"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			platformClient := platformMock.NewMockClienter(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+			contexts := map[string]string{"env": "prd"}
+			if test.expectList {
+				cdkClient.EXPECT().List(ctx, "cdkPath", contexts).Return(listed, nil)
+			}
+			if test.comment != "" {
+				platformClient.EXPECT().CreateComment(ctx, test.comment).Return(nil)
+			}
+			runner := &Runner{
+				platform: platformClient,
+				cdk:      cdkClient,
+			}
+			stacks, unmatched, err := runner.resolveStacks(ctx, "cdkPath", test.inStacks, contexts)
+			assert.Nil(t, err)
+			assert.Equal(t, test.outStacks, stacks)
+			assert.Equal(t, test.outUnmatched, unmatched)
+		})
+	}
+}
+
+func TestRunner_Deploy_BaseConfig(t *testing.T) {
+	tests := []struct {
+		title      string
+		headConfig string
+		baseConfig string
+		inContexts map[string]string
+		// output of cdk diff before deploying
+		preDiff  string
+		comment  string
+		outState *resultState
+	}{
+		{
+			title:      "pr_lowers_required_approvals",
+			headConfig: "cdkRoot: .\ntargets:\n  master:\n    requiredApprovals: 0\n",
+			baseConfig: "cdkRoot: .\ntargets:\n  master:\n    requiredApprovals: 2\n",
+			outState:   newResultState(constant.StateNotMergeReady, "0/2 approvals on the head commit. Get approvals before /deploy"),
+		},
+		{
+			title:      "pr_allows_overriding_contexts",
+			headConfig: "cdkRoot: .\ntargets:\n  master:\n    overridableContexts:\n      - version\n",
+			baseConfig: "cdkRoot: .\ntargets:\n  master: {}\n",
+			inContexts: map[string]string{"version": "2"},
+			outState:   newResultState(constant.StateNotMergeReady, "context version is not overridable. Add it to overridableContexts of the target."),
+		},
+		{
+			title:      "pr_disables_stateful_resource_check",
+			headConfig: "cdkRoot: .\ntargets:\n  master:\n    statefulResourceTypes: []\n",
+			baseConfig: "cdkRoot: .\ntargets:\n  master: {}\n",
+			preDiff:    "Stack Stack1\nResources\n[-] AWS::RDS::DBInstance Db DbABCD1234 destroy",
+			comment: blockedDeployHeader + "Replacement or deletion of the following stateful resources is not acknowledged.\n\n" +
+				"| Stack | Logical ID | Type | Change |\n| --- | --- | --- | --- |\n| Stack1 | DbABCD1234 | AWS::RDS::DBInstance | destroy |\n\n" +
+				"To deploy them anyway, run /deploy with `--allow-replace=DbABCD1234` or add the `cdkbot:allow replace` label to the PR.",
+			outState: newResultState(constant.StateNotMergeReady, "Replacement or deletion of stateful resources is not acknowledged"),
+		},
+		{
+			title:      "pr_allows_user",
+			headConfig: "cdkRoot: .\ntargets:\n  master: {}\ndeployUsers:\n  - foobar\n",
+			baseConfig: "cdkRoot: .\ntargets:\n  master: {}\ndeployUsers:\n  - sambaiz\n",
+			outState:   newResultState(constant.StateNotMergeReady, "user foobar is not allowed to deploy"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			// hasOutdatedDiffs()
+			platformClient.EXPECT().GetPullRequest(ctx).Return(&platform.PullRequest{}, nil)
+			// updateStatus()
+			platformClient.EXPECT().SetStatus(ctx, constant.StateRunning, "").Return(nil)
+			platformClient.EXPECT().AddLabel(ctx, constant.LabelRunning).Return(nil)
+			platformClient.EXPECT().SetStatus(ctx, test.outState.state, test.outState.description).Return(nil)
+			platformClient.EXPECT().RemoveLabel(ctx, constant.LabelRunning).Return(nil)
+
+			pr := &platform.PullRequest{Number: 1, BaseBranch: "master", BaseCommitHash: "basehash", HeadCommitHash: "headhash", Author: "author"}
+			runner := constructSetupWithConfigFiles(t, ctx, platformClient, gitClient, cdkClient, pr, test.headConfig, test.baseConfig)
+			if strings.Contains(test.outState.description, "approvals") {
+				platformClient.EXPECT().ListReviews(ctx).Return([]platform.Review{}, nil)
+			}
+			if test.preDiff != "" {
+				cdkPath := fmt.Sprintf("%s/.", runner.clonePath("master"))
+				platformClient.EXPECT().GetOpenPullRequests(ctx).Return([]platform.PullRequest{}, nil)
+				cdkClient.EXPECT().Dependencies(ctx, cdkPath, []string{"Stack1"}, map[string]string{}).Return([]string{"Stack1"}, nil)
+				cdkClient.EXPECT().Diff(ctx, cdkPath, []string{"Stack1"}, map[string]string{}).Return(test.preDiff, true, nil)
+				platformClient.EXPECT().CreateComment(ctx, test.comment).Return(nil)
+			}
+			assert.Nil(t, runner.Deploy(ctx, "foobar", []string{"Stack1"}, nil, test.inContexts, nil))
+		})
+	}
+}
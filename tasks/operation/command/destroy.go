@@ -0,0 +1,90 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Prefix of the argument to confirm /destroy with the head commit hash
+const confirmOption = "--confirm="
+
+// The confirmation needs at least this length of the head commit hash
+const minConfirmHashLength = 7
+
+// Destroy runs cdk destroy.
+// Only stacks matching destroyableStacks of the target can be destroyed by users listed in deployUsers,
+// and it needs to be confirmed by commenting again with the head commit hash.
+func (r *Runner) Destroy(
+	ctx context.Context,
+	userName string,
+	stacks []string,
+	confirmHash string,
+) error {
+	if len(stacks) == 0 {
+		return r.platform.CreateComment(ctx, "Specify stacks to destroy such as `/destroy Stack1 Stack2`.")
+	}
+	cdkPath, cfg, target, pr, err := r.setup(ctx, true)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return r.platform.CreateComment(ctx, "No targets are matched")
+	}
+	if !cfg.IsUserAllowedDestroy(userName) {
+		return r.platform.CreateComment(ctx, fmt.Sprintf("user %s is not allowed to destroy", userName))
+	}
+	notDestroyable := []string{}
+	for _, stack := range stacks {
+		if !target.IsStackDestroyable(stack) {
+			notDestroyable = append(notDestroyable, stack)
+		}
+	}
+	if len(notDestroyable) != 0 {
+		return r.platform.CreateComment(
+			ctx,
+			fmt.Sprintf("Stacks not allowed to be destroyed: %s. Add them to destroyableStacks of the target.", strings.Join(notDestroyable, ", ")),
+		)
+	}
+	if len(confirmHash) < minConfirmHashLength || !strings.HasPrefix(pr.HeadCommitHash, confirmHash) {
+		return r.platform.CreateComment(
+			ctx,
+			fmt.Sprintf(
+				"To destroy %s, comment `/destroy %s%s %s`",
+				strings.Join(stacks, ", "),
+				confirmOption,
+				pr.HeadCommitHash,
+				strings.Join(stacks, " "),
+			),
+		)
+	}
+
+	result, destroyErr := r.cdk.Destroy(ctx, cdkPath, stacks, target.Contexts)
+	errMessage := ""
+	if destroyErr != nil {
+		errMessage = destroyErr.Error()
+	}
+	return r.platform.CreateComment(
+		ctx,
+		fmt.Sprintf("### cdk destroy\n```\n%s\n%s\n```\n%s", result, errMessage, stacksRecord(stacks, pr.HeadCommitHash)),
+	)
+}
+
+// parseDestroyCommand returns stacks and the commit hash to confirm
+// from the command such as "/destroy --confirm=<hash> Stack1 Stack2"
+func parseDestroyCommand(command string) ([]string, string, error) {
+	args := strings.Fields(command)
+	stacks := []string{}
+	confirmHash := ""
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, confirmOption) {
+			confirmHash = strings.TrimPrefix(arg, confirmOption)
+			continue
+		}
+		if err := validateStackName(arg); err != nil {
+			return nil, "", err
+		}
+		stacks = append(stacks, arg)
+	}
+	return stacks, confirmHash, nil
+}
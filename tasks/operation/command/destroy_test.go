@@ -0,0 +1,192 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cdkMock "github.com/sambaiz/cdkbot/tasks/operation/cdk/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_Destroy(t *testing.T) {
+	headHash := "0123456789abcdef"
+	tests := []struct {
+		title           string
+		inUserName      string
+		inStacks        []string
+		inConfirmHash   string
+		destroyError    error
+		expectDestroy   bool
+		expectedComment string
+	}{
+		{
+			title:           "user_is_not_allowed",
+			inUserName:      "foobar",
+			inStacks:        []string{"Preview-1"},
+			inConfirmHash:   headHash,
+			expectedComment: "user foobar is not allowed to destroy",
+		},
+		{
+			title:           "stack_is_not_destroyable",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Preview-1", "Production"},
+			inConfirmHash:   headHash,
+			expectedComment: "Stacks not allowed to be destroyed: Production. Add them to destroyableStacks of the target.",
+		},
+		{
+			title:           "not_confirmed",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Preview-1", "Preview-2"},
+			expectedComment: "To destroy Preview-1, Preview-2, comment `/destroy --confirm=0123456789abcdef Preview-1 Preview-2`",
+		},
+		{
+			title:           "confirmed_with_other_commit",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Preview-1"},
+			inConfirmHash:   "fedcba9",
+			expectedComment: "To destroy Preview-1, comment `/destroy --confirm=0123456789abcdef Preview-1`",
+		},
+		{
+			title:           "confirmed",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Preview-1"},
+			inConfirmHash:   "0123456",
+			expectDestroy:   true,
+			expectedComment: "### cdk destroy\n```\nresult\n\n```\nStacks: Preview-1 at 0123456789abcdef",
+		},
+		{
+			title:           "destroy_error",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Preview-1"},
+			inConfirmHash:   headHash,
+			destroyError:    errors.New("cdk destroy error"),
+			expectDestroy:   true,
+			expectedComment: "### cdk destroy\n```\nresult\ncdk destroy error\n```\nStacks: Preview-1 at 0123456789abcdef",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			cfg := config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts:          map[string]string{"env": "stg"},
+						DestroyableStacks: []string{"Preview-*"},
+					},
+				},
+				DeployUsers: []string{"sambaiz"},
+			}
+			constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+				HeadCommitHash: headHash,
+			})
+			if test.expectDestroy {
+				cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), cfg.CDKRoot)
+				cdkClient.EXPECT().Destroy(ctx, cdkPath, test.inStacks, cfg.Targets["develop"].Contexts).Return("result", test.destroyError)
+			}
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Destroy(ctx, test.inUserName, test.inStacks, test.inConfirmHash))
+		})
+	}
+}
+
+func TestRunner_Destroy_BaseConfig(t *testing.T) {
+	// the PR tries to allow the user to destroy any stacks
+	headConfig := "cdkRoot: .\ntargets:\n  develop:\n    destroyableStacks:\n      - \"*\"\ndeployUsers:\n  - foobar\n"
+	baseConfig := "cdkRoot: .\ntargets:\n  develop:\n    destroyableStacks:\n      - Preview-*\ndeployUsers:\n  - sambaiz\n"
+	tests := []struct {
+		title           string
+		inUserName      string
+		inStacks        []string
+		expectedComment string
+	}{
+		{
+			title:           "user_allowed_by_pr",
+			inUserName:      "foobar",
+			inStacks:        []string{"Preview-1"},
+			expectedComment: "user foobar is not allowed to destroy",
+		},
+		{
+			title:           "stack_destroyable_by_pr",
+			inUserName:      "sambaiz",
+			inStacks:        []string{"Production"},
+			expectedComment: "Stacks not allowed to be destroyed: Production. Add them to destroyableStacks of the target.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			pr := &platform.PullRequest{BaseBranch: "develop", BaseCommitHash: "basehash", HeadCommitHash: "0123456789abcdef"}
+			runner := constructSetupWithConfigFiles(t, ctx, platformClient, gitClient, cdkClient, pr, headConfig, baseConfig)
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+			assert.Nil(t, runner.Destroy(ctx, test.inUserName, test.inStacks, pr.HeadCommitHash))
+		})
+	}
+}
+
+func TestParseDestroyCommand(t *testing.T) {
+	tests := []struct {
+		in                  string
+		expectedStacks      []string
+		expectedConfirmHash string
+		isError             bool
+	}{
+		{
+			in:             "/destroy Stack1 Stack2",
+			expectedStacks: []string{"Stack1", "Stack2"},
+		},
+		{
+			in:                  "/destroy --confirm=abc1234 Stack1",
+			expectedStacks:      []string{"Stack1"},
+			expectedConfirmHash: "abc1234",
+		},
+		{
+			in:             "/destroy  Stack1 ",
+			expectedStacks: []string{"Stack1"},
+		},
+		{
+			in:      "/destroy Stack1 ;rm",
+			isError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			stacks, confirmHash, err := parseDestroyCommand(test.in)
+			assert.Equal(t, test.isError, err != nil)
+			assert.Equal(t, test.expectedStacks, stacks)
+			assert.Equal(t, test.expectedConfirmHash, confirmHash)
+		})
+	}
+}
@@ -3,17 +3,31 @@ package command
 import (
 	"context"
 	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
 	"github.com/sambaiz/cdkbot/tasks/operation/constant"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform"
 	"strings"
 )
 
-// Diff runs cdk diff
+// Diff runs cdk diff and evaluates policies.
+// If stacks are specified, only they are diffed and the PR isn't regarded as merge ready.
 func (r *Runner) Diff(
 	ctx context.Context,
+	stacks []string,
 ) error {
-	return r.updateStatus(ctx, func() (*resultState, error) {
-		cdkPath, _, target, _, err := r.setup(ctx, true)
+	if done, err := r.handleRedelivery(ctx, "diff"); done || err != nil {
+		return err
+	}
+	return r.diff(ctx, stacks)
+}
+
+// diff is Diff run by a command whose redelivery is already handled
+func (r *Runner) diff(
+	ctx context.Context,
+	stacks []string,
+) error {
+	return r.updateStatus(ctx, "diff", func() (*resultState, error) {
+		cdkPath, cfg, target, pr, err := r.setup(ctx, true)
 		if err != nil {
 			return nil, err
 		}
@@ -21,14 +35,41 @@ func (r *Runner) Diff(
 			return newResultState(constant.StateMergeReady, "No targets are matched"), nil
 		}
 
+		diff, hasDiff, diffErr := r.cdk.Diff(ctx, cdkPath, stacks, target.Contexts)
+		var policies *policyResult
+		if diffErr == nil {
+			policies = r.checkPolicies(ctx, cdkPath, cfg, pr, stacks, target.Contexts, diff)
+		}
+		comment := formatDiff(diff)
+		if policies != nil {
+			comment += "\n\n" + policies.report()
+		}
+		if len(stacks) != 0 {
+			// Previous diff comments are left because this doesn't cover all stacks
+			if err := r.platform.CreateComment(
+				ctx,
+				fmt.Sprintf("### cdk diff %s\n%s", strings.Join(stacks, " "), comment),
+			); err != nil {
+				return nil, err
+			}
+			if diffErr != nil {
+				return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(diffErr.Error(), nil, cfg.CDKRoot), nil
+			}
+			if policies.blocked() {
+				return newResultState(constant.StateNotMergeReady, policies.description()).withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
+			}
+			if hasDiff {
+				return newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed").withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
+			}
+			return newResultState(constant.StateNotMergeReady, "No diffs in the stacks. Run /diff to check all stacks").withDetail(diff, nil, cfg.CDKRoot), nil
+		}
 		comments, err := r.platform.ListComments(ctx)
 		if err != nil {
 			return nil, err
 		}
-		diff, hasDiff, diffErr := r.cdk.Diff(cdkPath, nil, target.Contexts)
 		if err := r.platform.CreateComment(
 			ctx,
-			fmt.Sprintf("### cdk diff\n```\n%s\n```", diff),
+			fmt.Sprintf("### cdk diff\n%s", comment),
 		); err != nil {
 			return nil, err
 		}
@@ -37,15 +78,18 @@ func (r *Runner) Diff(
 			return nil, err
 		}
 		if diffErr != nil {
-			return newResultState(constant.StateNotMergeReady, "Fix codes"), nil
+			return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(diffErr.Error(), nil, cfg.CDKRoot), nil
 		}
 		if err := r.platform.RemoveLabel(ctx, constant.LabelOutdatedDiff); err != nil {
 			return nil, err
 		}
+		if policies.blocked() {
+			return newResultState(constant.StateNotMergeReady, policies.description()).withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
+		}
 		if hasDiff {
-			return newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed"), nil
+			return newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed").withDetail(diff, changedStacks(diff), cfg.CDKRoot), nil
 		}
-		return newResultState(constant.StateMergeReady, "No diffs. Let's merge!"), nil
+		return newResultState(constant.StateMergeReady, "No diffs. Let's merge!").withDetail(diff, nil, cfg.CDKRoot), nil
 	})
 }
 
@@ -54,7 +98,8 @@ func (r *Runner) deleteDiffCommentsUpToPreviousDeploy(ctx context.Context, comme
 		if strings.HasPrefix(comments[i].Body, "### cdk deploy\n") {
 			return nil
 		}
-		if strings.HasPrefix(comments[i].Body, "### cdk diff\n") {
+		// including the ones of the partial diff e.g. "### cdk diff Stack1"
+		if strings.HasPrefix(comments[i].Body, "### cdk diff\n") || strings.HasPrefix(comments[i].Body, "### cdk diff ") {
 			if err := r.platform.DeleteComment(ctx, comments[i].ID); err != nil {
 				return err
 			}
@@ -62,3 +107,89 @@ func (r *Runner) deleteDiffCommentsUpToPreviousDeploy(ctx context.Context, comme
 	}
 	return nil
 }
+
+// changedStacks returns stacks having differences in the output of cdk diff
+func changedStacks(diff string) []string {
+	stacks := []string{}
+	for _, stackDiff := range cdk.ParseDiff(diff) {
+		if stackDiff.HasChanges() {
+			stacks = append(stacks, stackDiff.Stack)
+		}
+	}
+	return stacks
+}
+
+// formatDiff renders a summary table of the changed stacks and the diff of each stack in a collapsible section.
+// The output is shown as it is if no stacks are found in it e.g. cdk diff fails.
+func formatDiff(diff string) string {
+	stackDiffs := cdk.ParseDiff(diff)
+	if len(stackDiffs) == 0 {
+		return fmt.Sprintf("```\n%s\n```", diff)
+	}
+	changed := []cdk.StackDiff{}
+	unchanged := []string{}
+	for _, stackDiff := range stackDiffs {
+		if stackDiff.HasChanges() {
+			changed = append(changed, stackDiff)
+		} else {
+			unchanged = append(unchanged, stackDiff.Stack)
+		}
+	}
+	lines := []string{}
+	if len(changed) != 0 {
+		lines = append(lines,
+			"| Stack | Added | Modified | Removed | Replaced | IAM | Security Groups |",
+			"| --- | ---: | ---: | ---: | ---: | :---: | :---: |",
+		)
+		for _, stackDiff := range changed {
+			var added, modified, removed, replaced int
+			for _, resource := range stackDiff.Resources {
+				switch {
+				case resource.Type == cdk.ChangeTypeAdd:
+					added++
+				case resource.Type == cdk.ChangeTypeRemove:
+					removed++
+				case resource.IsReplacedOrRemoved():
+					replaced++
+				default:
+					modified++
+				}
+			}
+			lines = append(lines, fmt.Sprintf(
+				"| %s | %d | %d | %d | %d | %s | %s |",
+				stackDiff.Stack,
+				added,
+				modified,
+				removed,
+				replaced,
+				changeMark(len(stackDiff.IAMStatements) != 0 || len(stackDiff.IAMPolicies) != 0),
+				changeMark(len(stackDiff.SecurityGroups) != 0),
+			))
+		}
+		lines = append(lines, "")
+	}
+	if len(unchanged) != 0 {
+		lines = append(lines, fmt.Sprintf("No differences: %s", strings.Join(unchanged, " ")), "")
+	}
+	for _, stackDiff := range changed {
+		lines = append(lines,
+			"<details>",
+			fmt.Sprintf("<summary>%s</summary>", stackDiff.Stack),
+			"",
+			"```",
+			stackDiff.Output,
+			"```",
+			"</details>",
+			"",
+		)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// changeMark returns a mark shown in the summary table if there are changes
+func changeMark(changed bool) string {
+	if changed {
+		return "⚠️"
+	}
+	return ""
+}
@@ -11,11 +11,12 @@ import (
 	"github.com/sambaiz/cdkbot/tasks/operation/logger"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform"
 	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"strings"
 	"testing"
 
 	"errors"
-	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 func TestRunner_Diff(t *testing.T) {
@@ -27,6 +28,7 @@ func TestRunner_Diff(t *testing.T) {
 		title         string
 		cfg           config.Config
 		baseBranch    string
+		stacks        []string
 		resultHasDiff bool
 		diffError     error
 		expected      expected
@@ -103,6 +105,46 @@ func TestRunner_Diff(t *testing.T) {
 				isError:  false,
 			},
 		},
+		{
+			title: "specified_stacks_have_no_diffs",
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts: map[string]string{
+							"env": "stg",
+						},
+					},
+				},
+			},
+			baseBranch:    "develop",
+			stacks:        []string{"Stack1", "Stack2"},
+			resultHasDiff: false,
+			expected: expected{
+				outState: newResultState(constant.StateNotMergeReady, "No diffs in the stacks. Run /diff to check all stacks"),
+				isError:  false,
+			},
+		},
+		{
+			title: "specified_stacks_have_diffs",
+			cfg: config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts: map[string]string{
+							"env": "stg",
+						},
+					},
+				},
+			},
+			baseBranch:    "develop",
+			stacks:        []string{"Stack1"},
+			resultHasDiff: true,
+			expected: expected{
+				outState: newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed"),
+				isError:  false,
+			},
+		},
 	}
 
 	constructRunnerWithMock := func(
@@ -110,6 +152,7 @@ func TestRunner_Diff(t *testing.T) {
 		ctrl *gomock.Controller,
 		cfg config.Config,
 		baseBranch string,
+		stacks []string,
 		resultHasDiff bool,
 		diffError error,
 		expected expected,
@@ -152,10 +195,20 @@ func TestRunner_Diff(t *testing.T) {
 			}
 		}
 
-		platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
-		cdkPath := fmt.Sprintf("%s/%s", clonePath, cfg.CDKRoot)
+		cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath(baseBranch), cfg.CDKRoot)
 		result := "result"
-		cdkClient.EXPECT().Diff(cdkPath, nil, target.Contexts).Return(result, resultHasDiff, diffError)
+		cdkClient.EXPECT().Diff(ctx, cdkPath, stacks, target.Contexts).Return(result, resultHasDiff, diffError)
+		if len(stacks) != 0 {
+			platformClient.EXPECT().CreateComment(ctx, fmt.Sprintf("### cdk diff %s\n```\n%s\n```", strings.Join(stacks, " "), result)).Return(nil)
+			return &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
+		}
+		platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
 		platformClient.EXPECT().CreateComment(ctx, fmt.Sprintf("### cdk diff\n```\n%s\n```", result)).Return(nil)
 		if diffError != nil {
 			return &Runner{
@@ -187,10 +240,11 @@ func TestRunner_Diff(t *testing.T) {
 				ctrl,
 				test.cfg,
 				test.baseBranch,
+				test.stacks,
 				test.resultHasDiff,
 				test.diffError,
 				test.expected)
-			assert.Equal(t, test.expected.isError, runner.Diff(ctx) != nil)
+			assert.Equal(t, test.expected.isError, runner.Diff(ctx, test.stacks) != nil)
 		})
 	}
 }
@@ -218,6 +272,16 @@ func TestRunner_deleteDiffCommentsUpToPreviousDeploy(t *testing.T) {
 			},
 			expectedDeletedIDs: []int64{1, 2},
 		},
+		{
+			title: "Delete_diff_comments_of_specified_stacks",
+			in: []platform.Comment{
+				{
+					ID:   1,
+					Body: "### cdk diff Stack1\n```\nresult\n```",
+				},
+			},
+			expectedDeletedIDs: []int64{1},
+		},
 		{
 			title: "Don't_delete_anything_other_than_diff_comments",
 			in: []platform.Comment{
@@ -264,3 +328,124 @@ func TestRunner_deleteDiffCommentsUpToPreviousDeploy(t *testing.T) {
 		})
 	}
 }
+
+func TestChangedStacks(t *testing.T) {
+	tests := []struct {
+		title string
+		in    string
+		out   []string
+	}{
+		{
+			title: "has_diffs",
+			in: `Stack Stack1
+Resources
+[+] AWS::SNS::Topic Topic Topic2A4C2B8F
+
+Stack Stack2
+There were no differences
+
+Stack Stage/Stack3 (Stage-Stack3)
+Resources
+[-] AWS::SQS::Queue Queue Queue4A7E3555 destroy
+
+✨  Number of stacks with differences: 2`,
+			out: []string{"Stack1", "Stage/Stack3"},
+		},
+		{
+			title: "has_no_diffs",
+			in: `Stack Stack1
+There were no differences`,
+			out: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.out, changedStacks(test.in))
+		})
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	tests := []struct {
+		title string
+		in    string
+		out   string
+	}{
+		{
+			title: "has_diffs",
+			in: `Stack Stack1
+IAM Statement Changes
+┌───┬────────────────┬────────┬────────────────┬──────────────────────────────┬───────────┐
+│   │ Resource       │ Effect │ Action         │ Principal                    │ Condition │
+├───┼────────────────┼────────┼────────────────┼──────────────────────────────┼───────────┤
+│ + │ ${Fn/Role.Arn} │ Allow  │ sts:AssumeRole │ Service:lambda.amazonaws.com │           │
+└───┴────────────────┴────────┴────────────────┴──────────────────────────────┴───────────┘
+Resources
+[+] AWS::IAM::Role Fn/Role FnRole03C8B6F4
+[~] AWS::Lambda::Function Fn Fn9270CBC0
+ └─ [~] Runtime
+     ├─ [-] nodejs18.x
+     └─ [+] nodejs20.x
+[~] AWS::RDS::DBInstance Db DbABCD1234 replace
+[-] AWS::SNS::Topic Topic Topic2A4C2B8F destroy
+
+Stack Stack2
+There were no differences
+
+Stack Stack3
+There were no differences
+
+Stack Stage/Stack4 (Stage-Stack4)
+Outputs
+[+] Output Url Url: {"Value":"https://example.com"}
+
+✨  Number of stacks with differences: 2`,
+			out: "| Stack | Added | Modified | Removed | Replaced | IAM | Security Groups |\n" +
+				"| --- | ---: | ---: | ---: | ---: | :---: | :---: |\n" +
+				"| Stack1 | 1 | 1 | 1 | 1 | ⚠️ |  |\n" +
+				"| Stage/Stack4 | 0 | 0 | 0 | 0 |  |  |\n" +
+				"\n" +
+				"No differences: Stack2 Stack3\n" +
+				"\n" +
+				"<details>\n<summary>Stack1</summary>\n\n```\n" +
+				`Stack Stack1
+IAM Statement Changes
+┌───┬────────────────┬────────┬────────────────┬──────────────────────────────┬───────────┐
+│   │ Resource       │ Effect │ Action         │ Principal                    │ Condition │
+├───┼────────────────┼────────┼────────────────┼──────────────────────────────┼───────────┤
+│ + │ ${Fn/Role.Arn} │ Allow  │ sts:AssumeRole │ Service:lambda.amazonaws.com │           │
+└───┴────────────────┴────────┴────────────────┴──────────────────────────────┴───────────┘
+Resources
+[+] AWS::IAM::Role Fn/Role FnRole03C8B6F4
+[~] AWS::Lambda::Function Fn Fn9270CBC0
+ └─ [~] Runtime
+     ├─ [-] nodejs18.x
+     └─ [+] nodejs20.x
+[~] AWS::RDS::DBInstance Db DbABCD1234 replace
+[-] AWS::SNS::Topic Topic Topic2A4C2B8F destroy` +
+				"\n```\n</details>\n\n" +
+				"<details>\n<summary>Stage/Stack4</summary>\n\n```\n" +
+				"Stack Stage/Stack4 (Stage-Stack4)\nOutputs\n[+] Output Url Url: {\"Value\":\"https://example.com\"}" +
+				"\n```\n</details>",
+		},
+		{
+			title: "has_no_diffs",
+			in: `Stack Stack1
+There were no differences
+
+Stack Stack2
+There were no differences`,
+			out: "No differences: Stack1 Stack2",
+		},
+		{
+			title: "no_stacks",
+			in:    "failed!",
+			out:   "```\nfailed!\n```",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.out, formatDiff(test.in))
+		})
+	}
+}
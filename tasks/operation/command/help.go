@@ -0,0 +1,104 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commandUsages are shown by /help in this order
+var commandUsages = []struct {
+	name        string
+	usage       string
+	description string
+}{
+	{"/diff", "/diff [stack1 stack2 ...]", "cdk diff. If not specify stacks, all stacks are passed."},
+	{"/deploy", "/deploy [--exclusively] [--hotswap] [-c key=value] [--allow-replace=LogicalId] [stack1 stack2 ...]", "cdk deploy. If not specify stacks, all stacks are passed. PR is merged automatically if there are no differences anymore. Only contexts listed in overridableContexts of the target can be overridden. Stacks can be specified with wildcards such as Prod-* and Stage/*. Replacing or deleting stateful resources needs --allow-replace or the cdkbot:allow replace label."},
+	{"/rollback", "/rollback [stack1 stack2 ...]", "cdk deploy at base branch. Only deployed PR can be roll backed."},
+	{"/cancel", "/cancel", "Stop running /deploy or /rollback gracefully and show which stacks finished."},
+	{"/destroy", "/destroy stack1 [stack2 ...]", "cdk destroy stacks matching destroyableStacks of the target. Confirmed by commenting again with the head commit hash."},
+	{"/synth", "/synth [stack1 stack2 ...]", "cdk synth and show the templates. If not specify stacks, all stacks are passed."},
+	{"/status", "/status", "Show labels, the target, deployed stacks and whether other PR blocks deploying."},
+	{"/unlock", "/unlock", "Remove the running and deployed labels left by a crashed or abandoned PR. Only users listed in adminUsers are allowed."},
+	{"/help", "/help", "Show this help."},
+}
+
+// Help comments the available commands and the settings of the target
+func (r *Runner) Help(ctx context.Context) error {
+	cfg, pr, err := r.readBaseConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"### cdkbot help", "", "Commands:", ""}
+	for _, command := range commandUsages {
+		lines = append(lines, fmt.Sprintf("- `%s`: %s", command.usage, command.description))
+	}
+	lines = append(lines, "")
+	target, ok := cfg.Targets[pr.BaseBranch]
+	if !ok {
+		lines = append(lines, fmt.Sprintf("No targets are matched with the base branch `%s`, so commands are not run.", pr.BaseBranch))
+	} else {
+		lines = append(lines, fmt.Sprintf("Target: `%s`", pr.BaseBranch))
+		contexts := []string{}
+		for k, v := range target.Contexts {
+			contexts = append(contexts, fmt.Sprintf("`%s=%s`", k, v))
+		}
+		sort.Strings(contexts)
+		if len(contexts) == 0 {
+			contexts = append(contexts, "none")
+		}
+		lines = append(lines, fmt.Sprintf("Contexts: %s", strings.Join(contexts, " ")))
+	}
+	if len(cfg.DeployUsers) == 0 {
+		lines = append(lines, "Deploy users: all users are allowed to deploy")
+	} else {
+		lines = append(lines, fmt.Sprintf("Deploy users: %s", strings.Join(cfg.DeployUsers, ", ")))
+	}
+	return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+}
+
+// replyUnknownCommand comments the command closest to the unknown one
+func (r *Runner) replyUnknownCommand(ctx context.Context, name string) error {
+	message := fmt.Sprintf("Unknown command `%s`.", name)
+	if suggestion := suggestCommand(name); suggestion != "" {
+		message += fmt.Sprintf(" Did you mean `%s`?", suggestion)
+	}
+	return r.platform.CreateComment(ctx, message+" Run `/help` to see available commands.")
+}
+
+// suggestCommand returns the command within edit distance 2 of name, or empty if there is no such command
+func suggestCommand(name string) string {
+	suggestion := ""
+	minDistance := 3
+	for _, command := range commandUsages {
+		if distance := editDistance(name, command.name); distance < minDistance {
+			suggestion = command.name
+			minDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// editDistance returns Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_Help(t *testing.T) {
+	usages := strings.Join([]string{
+		"### cdkbot help",
+		"",
+		"Commands:",
+		"",
+		"- `/diff [stack1 stack2 ...]`: cdk diff. If not specify stacks, all stacks are passed.",
+		"- `/deploy [--exclusively] [--hotswap] [-c key=value] [--allow-replace=LogicalId] [stack1 stack2 ...]`: cdk deploy. If not specify stacks, all stacks are passed. PR is merged automatically if there are no differences anymore. Only contexts listed in overridableContexts of the target can be overridden. Stacks can be specified with wildcards such as Prod-* and Stage/*. Replacing or deleting stateful resources needs --allow-replace or the cdkbot:allow replace label.",
+		"- `/rollback [stack1 stack2 ...]`: cdk deploy at base branch. Only deployed PR can be roll backed.",
+		"- `/cancel`: Stop running /deploy or /rollback gracefully and show which stacks finished.",
+		"- `/destroy stack1 [stack2 ...]`: cdk destroy stacks matching destroyableStacks of the target. Confirmed by commenting again with the head commit hash.",
+		"- `/synth [stack1 stack2 ...]`: cdk synth and show the templates. If not specify stacks, all stacks are passed.",
+		"- `/status`: Show labels, the target, deployed stacks and whether other PR blocks deploying.",
+		"- `/unlock`: Remove the running and deployed labels left by a crashed or abandoned PR. Only users listed in adminUsers are allowed.",
+		"- `/help`: Show this help.",
+		"",
+	}, "\n") + "\n"
+	tests := []struct {
+		title           string
+		cfg             config.Config
+		expectedComment string
+	}{
+		{
+			title: "target_is_matched",
+			cfg: config.Config{
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts: map[string]string{
+							"env":    "stg",
+							"region": "us-east-1",
+						},
+					},
+				},
+				DeployUsers: []string{"alice", "bob"},
+			},
+			expectedComment: usages + "Target: `develop`\nContexts: `env=stg` `region=us-east-1`\nDeploy users: alice, bob",
+		},
+		{
+			title: "no_targets_are_matched",
+			cfg: config.Config{
+				Targets: map[string]config.Target{
+					"master": {},
+				},
+			},
+			expectedComment: usages + "No targets are matched with the base branch `develop`, so commands are not run.\nDeploy users: all users are allowed to deploy",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+
+			pr := &platform.PullRequest{
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+			}
+			clonePath := (&Runner{}).clonePath(pr.BaseBranch)
+			platformClient.EXPECT().GetPullRequest(ctx).Return(pr, nil)
+			gitClient.EXPECT().Clone(clonePath, &pr.BaseCommitHash).Return(nil)
+			configClient.EXPECT().Read(fmt.Sprintf("%s/cdkbot.yml", clonePath)).Return(&test.cfg, nil)
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Run(ctx, "/help", "user"))
+		})
+	}
+}
+
+func TestRunner_Run_UnknownCommand(t *testing.T) {
+	tests := []struct {
+		title           string
+		in              string
+		expectedComment string
+	}{
+		{
+			title:           "typo",
+			in:              "/deplyo Stack1",
+			expectedComment: "Unknown command `/deplyo`. Did you mean `/deploy`? Run `/help` to see available commands.",
+		},
+		{
+			title:           "prefixed_by_command",
+			in:              "/diffx",
+			expectedComment: "Unknown command `/diffx`. Did you mean `/diff`? Run `/help` to see available commands.",
+		},
+		{
+			title:           "command_with_suffix",
+			in:              "/deployed Stack1",
+			expectedComment: "Unknown command `/deployed`. Did you mean `/deploy`? Run `/help` to see available commands.",
+		},
+		{
+			title:           "no_similar_commands",
+			in:              "/approve",
+			expectedComment: "Unknown command `/approve`. Run `/help` to see available commands.",
+		},
+		{
+			title: "path",
+			in:    "/usr/bin is not found",
+		},
+		{
+			title: "not_command",
+			in:    "LGTM",
+		},
+		{
+			title: "blank",
+			in:    "  ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			if test.expectedComment != "" {
+				platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+			}
+			runner := &Runner{
+				platform: platformClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Run(ctx, test.in, "user"))
+		})
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"/dif", "/diff"},
+		{"/deplyo", "/deploy"},
+		{"/rolback", "/rollback"},
+		{"/hlep", "/help"},
+		{"/stauts", "/status"},
+		{"/synt", "/synth"},
+		{"/destory", "/destroy"},
+		{"/cancle", "/cancel"},
+		{"/unlcok", "/unlock"},
+		{"/approve", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			assert.Equal(t, test.out, suggestCommand(test.in))
+		})
+	}
+}
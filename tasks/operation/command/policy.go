@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/sambaiz/cdkbot/tasks/operation/policy"
+	"path/filepath"
+	"strings"
+)
+
+// policyResult is the result of evaluating the policies of the target
+type policyResult struct {
+	rules      int
+	violations []policy.Violation
+	// err is set if the policies can't be evaluated
+	err error
+}
+
+// checkPolicies evaluates the policies of the target against the synthesized templates of the stacks and the diff.
+// All stacks are evaluated if stacks are empty. It returns nil if no policies are applied to the target.
+// Failures to load or evaluate the policies are returned in the result to be reported in the PR.
+func (r *Runner) checkPolicies(
+	ctx context.Context,
+	cdkPath string,
+	cfg *config.Config,
+	pr *platform.PullRequest,
+	stacks []string,
+	contexts map[string]string,
+	diff string,
+) *policyResult {
+	if cfg.PolicyDir == "" {
+		return nil
+	}
+	rules, err := r.policy.Load(filepath.Join(r.clonePath(pr.BaseBranch), cfg.PolicyDir))
+	if err != nil {
+		return &policyResult{err: err}
+	}
+	applied := []policy.Rule{}
+	for _, rule := range rules {
+		if rule.AppliesTo(pr.BaseBranch) {
+			applied = append(applied, rule)
+		}
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+	templates, err := r.cdk.Synth(ctx, cdkPath, nil, contexts)
+	if err != nil {
+		return &policyResult{rules: len(applied), err: err}
+	}
+	if len(stacks) != 0 {
+		for stack := range templates {
+			if matched, _ := cdk.MatchStacks(stacks, []string{stack}); len(matched) == 0 {
+				delete(templates, stack)
+			}
+		}
+	}
+	violations, err := policy.Evaluate(applied, pr.BaseBranch, templates, cdk.ParseDiff(diff))
+	if err != nil {
+		return &policyResult{rules: len(applied), err: err}
+	}
+	return &policyResult{
+		rules:      len(applied),
+		violations: violations,
+	}
+}
+
+// blocked returns whether /deploy is blocked by the policies
+func (p *policyResult) blocked() bool {
+	return p != nil && (p.err != nil || len(p.violations) != 0)
+}
+
+// description is shown in the status if deploying is blocked
+func (p *policyResult) description() string {
+	if p.err != nil {
+		return "Policy checks failed. Fix them before /deploy"
+	}
+	return fmt.Sprintf("Policy violations: %d. Fix them before /deploy", len(p.violations))
+}
+
+// report shows the violations in a table
+func (p *policyResult) report() string {
+	if p.err != nil {
+		return fmt.Sprintf("#### Policy checks\nFailed to check the policies.\n```\n%s\n```", p.err.Error())
+	}
+	if len(p.violations) == 0 {
+		return fmt.Sprintf("#### Policy checks\nAll %d rules passed.", p.rules)
+	}
+	lines := []string{
+		"#### Policy checks",
+		fmt.Sprintf("%d violations of %d rules.", len(p.violations), p.rules),
+		"",
+		"| Rule | Stack | Resource | Description |",
+		"| --- | --- | --- | --- |",
+	}
+	for _, violation := range p.violations {
+		resource := []string{}
+		if violation.LogicalID != "" {
+			resource = append(resource, fmt.Sprintf("%s (%s)", violation.LogicalID, violation.ResourceType))
+		}
+		if violation.Reason != "" {
+			resource = append(resource, violation.Reason)
+		}
+		lines = append(lines, fmt.Sprintf(
+			"| %s | %s | %s | %s |",
+			violation.Rule,
+			violation.Stack,
+			strings.Join(resource, " "),
+			violation.Description,
+		))
+	}
+	return strings.Join(lines, "\n")
+}
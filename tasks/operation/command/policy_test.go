@@ -0,0 +1,365 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cdkMock "github.com/sambaiz/cdkbot/tasks/operation/cdk/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/policy"
+	policyMock "github.com/sambaiz/cdkbot/tasks/operation/policy/mock"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+var (
+	publicBucketRule = policy.Rule{
+		Name:          "no-public-s3-buckets",
+		Description:   "S3 buckets must block public access",
+		ResourceTypes: []string{"AWS::S3::Bucket"},
+		Require:       &policy.Condition{Path: "Properties.PublicAccessBlockConfiguration"},
+	}
+	prdOnlyRule = policy.Rule{
+		Name:    "no-iam-changes-in-prd",
+		Targets: []string{"master"},
+		Diff:    &policy.DiffCondition{IAM: true},
+	}
+	policyTemplates = map[string]string{
+		"Stack1":       `{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket", "Properties": {}}}}`,
+		"Stack2":       `{"Resources": {"Queue": {"Type": "AWS::SQS::Queue", "Properties": {}}}}`,
+		"Stage/Stack3": `{"Resources": {"Bucket": {"Type": "AWS::S3::Bucket", "Properties": {}}}}`,
+	}
+)
+
+func TestRunner_checkPolicies(t *testing.T) {
+	tests := []struct {
+		title      string
+		policyDir  string
+		rules      []policy.Rule
+		inStacks   []string
+		expectLoad bool
+		loadErr    error
+		synthErr   error
+		out        *policyResult
+	}{
+		{
+			title: "no_policy_dir",
+			out:   nil,
+		},
+		{
+			title:      "no_rules_applied_to_the_target",
+			policyDir:  "policies",
+			rules:      []policy.Rule{prdOnlyRule},
+			expectLoad: true,
+			out:        nil,
+		},
+		{
+			title:      "violated",
+			policyDir:  "policies",
+			rules:      []policy.Rule{publicBucketRule, prdOnlyRule},
+			expectLoad: true,
+			out: &policyResult{
+				rules: 1,
+				violations: []policy.Violation{
+					{Rule: "no-public-s3-buckets", Description: "S3 buckets must block public access", Stack: "Stack1", LogicalID: "Bucket", ResourceType: "AWS::S3::Bucket"},
+					{Rule: "no-public-s3-buckets", Description: "S3 buckets must block public access", Stack: "Stage/Stack3", LogicalID: "Bucket", ResourceType: "AWS::S3::Bucket"},
+				},
+			},
+		},
+		{
+			title:      "stacks_in_stage",
+			policyDir:  "policies",
+			rules:      []policy.Rule{publicBucketRule},
+			inStacks:   []string{"Stage/*"},
+			expectLoad: true,
+			out: &policyResult{
+				rules: 1,
+				violations: []policy.Violation{
+					{Rule: "no-public-s3-buckets", Description: "S3 buckets must block public access", Stack: "Stage/Stack3", LogicalID: "Bucket", ResourceType: "AWS::S3::Bucket"},
+				},
+			},
+		},
+		{
+			title:      "only_specified_stacks",
+			policyDir:  "policies",
+			rules:      []policy.Rule{publicBucketRule},
+			inStacks:   []string{"Stack2"},
+			expectLoad: true,
+			out: &policyResult{
+				rules:      1,
+				violations: []policy.Violation{},
+			},
+		},
+		{
+			title:      "load_error",
+			policyDir:  "policies",
+			expectLoad: true,
+			loadErr:    errors.New("invalid policy"),
+			out:        &policyResult{err: errors.New("invalid policy")},
+		},
+		{
+			title:      "synth_error",
+			policyDir:  "policies",
+			rules:      []policy.Rule{publicBucketRule},
+			expectLoad: true,
+			synthErr:   errors.New("cdk synth failed"),
+			out:        &policyResult{rules: 1, err: errors.New("cdk synth failed")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			policyLoader := policyMock.NewMockLoaderer(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+			contexts := map[string]string{"env": "stg"}
+			if test.expectLoad {
+				policyLoader.EXPECT().Load(fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), test.policyDir)).Return(test.rules, test.loadErr)
+			}
+			if test.out != nil && test.loadErr == nil {
+				templates := map[string]string{}
+				for stack, template := range policyTemplates {
+					templates[stack] = template
+				}
+				cdkClient.EXPECT().Synth(ctx, "cdkPath", nil, contexts).Return(templates, test.synthErr)
+			}
+			runner := &Runner{
+				cdk:    cdkClient,
+				policy: policyLoader,
+			}
+			result := runner.checkPolicies(
+				ctx,
+				"cdkPath",
+				&config.Config{PolicyDir: test.policyDir},
+				&platform.PullRequest{BaseBranch: "develop"},
+				test.inStacks,
+				contexts,
+				"",
+			)
+			assert.Equal(t, test.out, result)
+		})
+	}
+}
+
+func TestPolicyResult_report(t *testing.T) {
+	assert.Equal(t, "#### Policy checks\nFailed to check the policies.\n```\ninvalid policy\n```", (&policyResult{err: errors.New("invalid policy")}).report())
+	assert.Equal(t, "#### Policy checks\nAll 2 rules passed.", (&policyResult{rules: 2, violations: []policy.Violation{}}).report())
+	assert.Equal(
+		t,
+		"#### Policy checks\n2 violations of 2 rules.\n\n"+
+			"| Rule | Stack | Resource | Description |\n| --- | --- | --- | --- |\n"+
+			"| no-public-s3-buckets | Stack1 | Bucket (AWS::S3::Bucket) | S3 buckets must block public access |\n"+
+			"| no-iam-changes | Stack2 | IAM changes |  |",
+		(&policyResult{rules: 2, violations: []policy.Violation{
+			{Rule: "no-public-s3-buckets", Description: "S3 buckets must block public access", Stack: "Stack1", LogicalID: "Bucket", ResourceType: "AWS::S3::Bucket"},
+			{Rule: "no-iam-changes", Stack: "Stack2", Reason: "IAM changes"},
+		}}).report(),
+	)
+}
+
+func TestRunner_Diff_PolicyViolations(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	platformClient := platformMock.NewMockClienter(ctrl)
+	gitClient := gitMock.NewMockClienter(ctrl)
+	configClient := configMock.NewMockReaderer(ctrl)
+	cdkClient := cdkMock.NewMockClienter(ctrl)
+	policyLoader := policyMock.NewMockLoaderer(ctrl)
+
+	cfg := config.Config{
+		CDKRoot:   ".",
+		Targets:   map[string]config.Target{"develop": {Contexts: map[string]string{"env": "stg"}}},
+		PolicyDir: "policies",
+	}
+	description := "Policy violations: 1. Fix them before /deploy"
+	// updateStatus()
+	platformClient.EXPECT().SetStatus(ctx, constant.StateRunning, "").Return(nil)
+	platformClient.EXPECT().AddLabel(ctx, constant.LabelRunning).Return(nil)
+	platformClient.EXPECT().SetStatus(ctx, constant.StateNotMergeReady, description).Return(nil)
+	platformClient.EXPECT().RemoveLabel(ctx, constant.LabelRunning).Return(nil)
+	constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+		BaseBranch:     "develop",
+		BaseCommitHash: "basehash",
+		HeadCommitHash: "headhash",
+	})
+	clonePath := (&Runner{}).clonePath("develop")
+	cdkPath := fmt.Sprintf("%s/%s", clonePath, cfg.CDKRoot)
+	cdkClient.EXPECT().Diff(ctx, cdkPath, nil, cfg.Targets["develop"].Contexts).Return("Stack Stack1\nThere were no differences", false, nil)
+	policyLoader.EXPECT().Load(fmt.Sprintf("%s/policies", clonePath)).Return([]policy.Rule{publicBucketRule}, nil)
+	cdkClient.EXPECT().Synth(ctx, cdkPath, nil, cfg.Targets["develop"].Contexts).Return(map[string]string{"Stack1": policyTemplates["Stack1"]}, nil)
+	platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
+	platformClient.EXPECT().CreateComment(ctx, "### cdk diff\nNo differences: Stack1\n\n#### Policy checks\n1 violations of 1 rules.\n\n"+
+		"| Rule | Stack | Resource | Description |\n| --- | --- | --- | --- |\n"+
+		"| no-public-s3-buckets | Stack1 | Bucket (AWS::S3::Bucket) | S3 buckets must block public access |").Return(nil)
+	platformClient.EXPECT().RemoveLabel(ctx, constant.LabelOutdatedDiff).Return(nil)
+
+	runner := &Runner{
+		platform: platformClient,
+		git:      gitClient,
+		config:   configClient,
+		cdk:      cdkClient,
+		policy:   policyLoader,
+		logger:   logger.MockLogger{},
+	}
+	assert.Nil(t, runner.Diff(ctx, nil))
+}
+
+func TestRunner_Diff_PolicyError(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	platformClient := platformMock.NewMockClienter(ctrl)
+	gitClient := gitMock.NewMockClienter(ctrl)
+	configClient := configMock.NewMockReaderer(ctrl)
+	cdkClient := cdkMock.NewMockClienter(ctrl)
+	policyLoader := policyMock.NewMockLoaderer(ctrl)
+
+	cfg := config.Config{
+		CDKRoot:   ".",
+		Targets:   map[string]config.Target{"develop": {Contexts: map[string]string{"env": "stg"}}},
+		PolicyDir: "policies",
+	}
+	// updateStatus()
+	platformClient.EXPECT().SetStatus(ctx, constant.StateRunning, "").Return(nil)
+	platformClient.EXPECT().AddLabel(ctx, constant.LabelRunning).Return(nil)
+	platformClient.EXPECT().SetStatus(ctx, constant.StateNotMergeReady, "Policy checks failed. Fix them before /deploy").Return(nil)
+	platformClient.EXPECT().RemoveLabel(ctx, constant.LabelRunning).Return(nil)
+	constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+		BaseBranch:     "develop",
+		BaseCommitHash: "basehash",
+		HeadCommitHash: "headhash",
+	})
+	clonePath := (&Runner{}).clonePath("develop")
+	cdkPath := fmt.Sprintf("%s/%s", clonePath, cfg.CDKRoot)
+	cdkClient.EXPECT().Diff(ctx, cdkPath, nil, cfg.Targets["develop"].Contexts).Return("Stack Stack1\nThere were no differences", false, nil)
+	policyLoader.EXPECT().Load(fmt.Sprintf("%s/policies", clonePath)).Return(nil, errors.New("invalid policy"))
+	platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
+	platformClient.EXPECT().CreateComment(ctx, "### cdk diff\nNo differences: Stack1\n\n#### Policy checks\nFailed to check the policies.\n```\ninvalid policy\n```").Return(nil)
+	platformClient.EXPECT().RemoveLabel(ctx, constant.LabelOutdatedDiff).Return(nil)
+
+	runner := &Runner{
+		platform: platformClient,
+		git:      gitClient,
+		config:   configClient,
+		cdk:      cdkClient,
+		policy:   policyLoader,
+		logger:   logger.MockLogger{},
+	}
+	assert.Nil(t, runner.Diff(ctx, nil))
+}
+
+func TestRunner_checkBeforeDeploy(t *testing.T) {
+	tests := []struct {
+		title     string
+		labels    map[string]constant.Label
+		policyDir string
+		flags     []string
+		// stacks deployed with Stack2
+		dependencies []string
+		diff         string
+		expectDiff   bool
+		comment      string
+		out          *resultState
+	}{
+		{
+			title:  "acknowledged_by_label_without_policies",
+			labels: map[string]constant.Label{constant.LabelAllowReplace.Name: constant.LabelAllowReplace},
+			out:    nil,
+		},
+		{
+			title:      "no_replacements",
+			diff:       "Stack Stack1\nResources\n[+] AWS::S3::Bucket Bucket Bucket",
+			expectDiff: true,
+			out:        nil,
+		},
+		{
+			title:        "policies_are_violated_in_dependency",
+			labels:       map[string]constant.Label{constant.LabelAllowReplace.Name: constant.LabelAllowReplace},
+			policyDir:    "policies",
+			dependencies: []string{"Stack1", "Stack2"},
+			diff:         "Stack Stack1\nResources\n[~] AWS::S3::Bucket Bucket Bucket replace",
+			expectDiff:   true,
+			comment: "### cdk deploy (blocked)\n#### Policy checks\n1 violations of 1 rules.\n\n" +
+				"| Rule | Stack | Resource | Description |\n| --- | --- | --- | --- |\n" +
+				"| no-public-s3-buckets | Stack1 | Bucket (AWS::S3::Bucket) | S3 buckets must block public access |",
+			out: newResultState(constant.StateNotMergeReady, "Policy violations: 1. Fix them before /deploy").
+				withDetail("Stack Stack1\nResources\n[~] AWS::S3::Bucket Bucket Bucket replace", []string{"Stack1"}, "."),
+		},
+		{
+			title:        "replaced_in_dependency",
+			dependencies: []string{"Stack1", "Stack2"},
+			diff:         "Stack Stack1\nResources\n[~] AWS::DynamoDB::Table Table TableCD117FA1 replace\nStack Stack2\nResources\n[+] AWS::S3::Bucket Bucket Bucket",
+			expectDiff:   true,
+			comment: "### cdk deploy (blocked)\nReplacement or deletion of the following stateful resources is not acknowledged.\n\n" +
+				"| Stack | Logical ID | Type | Change |\n| --- | --- | --- | --- |\n| Stack1 | TableCD117FA1 | AWS::DynamoDB::Table | replace |\n\n" +
+				"To deploy them anyway, run /deploy with `--allow-replace=TableCD117FA1` or add the `cdkbot:allow replace` label to the PR.",
+			out: newResultState(constant.StateNotMergeReady, "Replacement or deletion of stateful resources is not acknowledged").
+				withDetail("Stack Stack1\nResources\n[~] AWS::DynamoDB::Table Table TableCD117FA1 replace\nStack Stack2\nResources\n[+] AWS::S3::Bucket Bucket Bucket", []string{"Stack1", "Stack2"}, "."),
+		},
+		{
+			title:      "exclusively",
+			flags:      []string{"--exclusively"},
+			diff:       "Stack Stack2\nResources\n[+] AWS::S3::Bucket Bucket Bucket",
+			expectDiff: true,
+			out:        nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			platformClient := platformMock.NewMockClienter(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+			policyLoader := policyMock.NewMockLoaderer(ctrl)
+			stacks := []string{"Stack2"}
+			contexts := map[string]string{"env": "stg"}
+			if test.expectDiff {
+				diffStacks := stacks
+				if !slices.Contains(test.flags, "--exclusively") {
+					if test.dependencies != nil {
+						diffStacks = test.dependencies
+					}
+					cdkClient.EXPECT().Dependencies(ctx, "cdkPath", stacks, contexts).Return(diffStacks, nil)
+				}
+				cdkClient.EXPECT().Diff(ctx, "cdkPath", diffStacks, contexts).Return(test.diff, true, nil)
+			}
+			if test.policyDir != "" {
+				policyLoader.EXPECT().Load(fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), test.policyDir)).Return([]policy.Rule{publicBucketRule}, nil)
+				cdkClient.EXPECT().Synth(ctx, "cdkPath", nil, contexts).Return(map[string]string{"Stack1": policyTemplates["Stack1"]}, nil)
+			}
+			if test.comment != "" {
+				platformClient.EXPECT().CreateComment(ctx, test.comment).Return(nil)
+			}
+			runner := &Runner{
+				platform: platformClient,
+				cdk:      cdkClient,
+				policy:   policyLoader,
+			}
+			state, err := runner.checkBeforeDeploy(
+				ctx,
+				"cdkPath",
+				&config.Config{CDKRoot: ".", PolicyDir: test.policyDir},
+				&config.Target{},
+				&platform.PullRequest{BaseBranch: "develop", Labels: test.labels},
+				stacks,
+				test.flags,
+				contexts,
+				nil,
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, test.out, state)
+		})
+	}
+}
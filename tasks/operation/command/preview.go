@@ -0,0 +1,118 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"sort"
+)
+
+const (
+	previewDeployHeader  = "### cdk deploy (preview)\n"
+	previewDestroyHeader = "### cdk destroy (preview)\n"
+)
+
+// DeployPreview deploys the preview environment of the PR
+// with the context of the PR number if preview is enabled in the target
+func (r *Runner) DeployPreview(ctx context.Context) error {
+	if done, err := r.handleRedelivery(ctx, "preview deploy"); done || err != nil {
+		return err
+	}
+	cdkPath, _, target, pr, err := r.setup(ctx, true)
+	if err != nil {
+		return err
+	}
+	if target == nil || target.Preview == nil {
+		return nil
+	}
+	contexts := target.PreviewContexts(pr.Number)
+	stacks := target.Preview.Stacks
+	if len(stacks) == 0 || hasStackWildcard(stacks) {
+		listed, err := r.cdk.List(ctx, cdkPath, contexts)
+		if err != nil {
+			return err
+		}
+		if len(stacks) == 0 {
+			stacks = listed
+		} else {
+			// record the matched stacks so that DestroyPreview destroys what is actually deployed
+			stacks, _ = cdk.MatchStacks(stacks, listed)
+		}
+	}
+	if len(stacks) == 0 {
+		return r.platform.CreateComment(ctx, "No stacks are matched with the preview stacks, so nothing is deployed")
+	}
+	result, deployErr := r.cdk.Deploy(ctx, cdkPath, stacks, nil, contexts)
+	errMessage := ""
+	if deployErr != nil {
+		errMessage = deployErr.Error()
+	}
+	return r.platform.CreateComment(
+		ctx,
+		fmt.Sprintf("%s```\n%s\n%s\n```\n%s", previewDeployHeader, result, errMessage, stacksRecord(stacks, pr.HeadCommitHash)),
+	)
+}
+
+// DestroyPreview destroys the preview stacks deployed for the PR.
+// It runs at the base branch because the head branch may be deleted after the PR is closed,
+// so the preview stacks need to be defined in the base branch too.
+// Only stacks of the preview in the target are destroyed because comments recording them can be written by anyone.
+func (r *Runner) DestroyPreview(ctx context.Context) error {
+	if done, err := r.handleRedelivery(ctx, "preview destroy"); done || err != nil {
+		return err
+	}
+	comments, err := r.platform.ListComments(ctx)
+	if err != nil {
+		return err
+	}
+	previews := previewStacks(comments)
+	if len(previews) == 0 {
+		return nil
+	}
+	recorded := []string{}
+	for stack := range previews {
+		recorded = append(recorded, stack)
+	}
+	sort.Strings(recorded)
+
+	cdkPath, _, target, pr, err := r.setup(ctx, false)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return r.platform.CreateComment(ctx, "No targets are matched, so the preview stacks are not destroyed")
+	}
+	if target.Preview == nil {
+		return r.platform.CreateComment(ctx, "Preview is not enabled in the target, so the preview stacks are not destroyed")
+	}
+	contexts := target.PreviewContexts(pr.Number)
+	configured := target.Preview.Stacks
+	if len(configured) == 0 {
+		configured, err = r.cdk.List(ctx, cdkPath, contexts)
+		if err != nil {
+			return err
+		}
+	}
+	stacks, _ := cdk.MatchStacks(configured, recorded)
+	if len(stacks) == 0 {
+		return nil
+	}
+	result, destroyErr := r.cdk.Destroy(ctx, cdkPath, stacks, contexts)
+	if destroyErr != nil {
+		// not recorded as destroyed to be retried
+		return r.platform.CreateComment(
+			ctx,
+			fmt.Sprintf("### cdk destroy (preview) failed\n```\n%s\n%s\n```", result, destroyErr.Error()),
+		)
+	}
+	return r.platform.CreateComment(
+		ctx,
+		fmt.Sprintf("%s```\n%s\n```\n%s", previewDestroyHeader, result, stacksRecord(stacks, pr.BaseCommitHash)),
+	)
+}
+
+// previewStacks returns the map of preview stacks existing for the PR to the deployed commit
+func previewStacks(comments []platform.Comment) map[string]string {
+	return recordedStacks(comments, previewDeployHeader, previewDestroyHeader)
+}
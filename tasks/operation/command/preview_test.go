@@ -0,0 +1,229 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cdkMock "github.com/sambaiz/cdkbot/tasks/operation/cdk/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_DeployPreview(t *testing.T) {
+	tests := []struct {
+		title           string
+		preview         *config.Preview
+		listStacks      []string
+		deployError     error
+		expectedStacks  []string
+		expectedContext map[string]string
+		expectedComment string
+	}{
+		{
+			title:   "preview_is_disabled",
+			preview: nil,
+		},
+		{
+			title:           "stacks_are_specified",
+			preview:         &config.Preview{Stacks: []string{"Preview"}},
+			expectedStacks:  []string{"Preview"},
+			expectedContext: map[string]string{"env": "stg", "pr": "12"},
+			expectedComment: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at headhash",
+		},
+		{
+			title:           "all_stacks",
+			preview:         &config.Preview{ContextKey: "preview"},
+			listStacks:      []string{"Stack1", "Stack2"},
+			expectedStacks:  []string{"Stack1", "Stack2"},
+			expectedContext: map[string]string{"env": "stg", "preview": "12"},
+			expectedComment: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Stack1 Stack2 at headhash",
+		},
+		{
+			title:           "patterns",
+			preview:         &config.Preview{Stacks: []string{"Preview-*"}},
+			listStacks:      []string{"Preview-API", "Stack1", "Preview-Web"},
+			expectedStacks:  []string{"Preview-API", "Preview-Web"},
+			expectedContext: map[string]string{"env": "stg", "pr": "12"},
+			expectedComment: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview-API Preview-Web at headhash",
+		},
+		{
+			title:           "deploy_error",
+			preview:         &config.Preview{Stacks: []string{"Preview"}},
+			deployError:     errors.New("cdk deploy error"),
+			expectedStacks:  []string{"Preview"},
+			expectedContext: map[string]string{"env": "stg", "pr": "12"},
+			expectedComment: "### cdk deploy (preview)\n```\nresult\ncdk deploy error\n```\nStacks: Preview at headhash",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			cfg := config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {
+						Contexts: map[string]string{"env": "stg"},
+						Preview:  test.preview,
+					},
+				},
+			}
+			constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+				Number:         12,
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+				HeadCommitHash: "headhash",
+			})
+			cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), cfg.CDKRoot)
+			if test.listStacks != nil {
+				cdkClient.EXPECT().List(ctx, cdkPath, test.expectedContext).Return(test.listStacks, nil)
+			}
+			if test.expectedComment != "" {
+				cdkClient.EXPECT().Deploy(ctx, cdkPath, test.expectedStacks, nil, test.expectedContext).Return("result", test.deployError)
+				platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+			}
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.DeployPreview(ctx))
+		})
+	}
+}
+
+func TestRunner_DestroyPreview(t *testing.T) {
+	tests := []struct {
+		title           string
+		comments        []platform.Comment
+		preview         *config.Preview
+		listStacks      []string
+		destroyError    error
+		expectedStacks  []string
+		expectedComment string
+	}{
+		{
+			title:    "no_previews",
+			comments: []platform.Comment{{Body: "### cdk diff\n```\nresult\n```"}},
+		},
+		{
+			title: "already_destroyed",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at headhash"},
+				{Body: "### cdk destroy (preview)\n```\nresult\n```\nStacks: Preview at basehash"},
+			},
+		},
+		{
+			title: "destroyed",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview2 Preview1 at headhash"},
+			},
+			preview:         &config.Preview{},
+			listStacks:      []string{"Preview1", "Preview2"},
+			expectedStacks:  []string{"Preview1", "Preview2"},
+			expectedComment: "### cdk destroy (preview)\n```\nresult\n```\nStacks: Preview1 Preview2 at basehash",
+		},
+		{
+			title: "stacks_not_in_preview_are_not_destroyed",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at headhash"},
+				// written by a user
+				{Body: "### cdk deploy (preview)\nStacks: Production Preview-1 at headhash", User: "foobar"},
+			},
+			preview:         &config.Preview{Stacks: []string{"Preview", "Preview-*"}},
+			expectedStacks:  []string{"Preview", "Preview-1"},
+			expectedComment: "### cdk destroy (preview)\n```\nresult\n```\nStacks: Preview Preview-1 at basehash",
+		},
+		{
+			title: "no_stacks_in_preview",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\nStacks: Production at headhash", User: "foobar"},
+			},
+			preview: &config.Preview{Stacks: []string{"Preview"}},
+		},
+		{
+			title: "preview_is_disabled",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at headhash"},
+			},
+			expectedComment: "Preview is not enabled in the target, so the preview stacks are not destroyed",
+		},
+		{
+			title: "destroy_error",
+			comments: []platform.Comment{
+				{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at headhash"},
+			},
+			preview:         &config.Preview{Stacks: []string{"Preview"}},
+			destroyError:    errors.New("cdk destroy error"),
+			expectedStacks:  []string{"Preview"},
+			expectedComment: "### cdk destroy (preview) failed\n```\nresult\ncdk destroy error\n```",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			platformClient.EXPECT().ListComments(ctx).Return(test.comments, nil)
+			if len(previewStacks(test.comments)) != 0 {
+				cfg := config.Config{
+					CDKRoot: ".",
+					Targets: map[string]config.Target{
+						"develop": {
+							Contexts: map[string]string{"env": "stg"},
+							Preview:  test.preview,
+						},
+					},
+				}
+				constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, false, cfg, &platform.PullRequest{
+					Number:         12,
+					BaseBranch:     "develop",
+					BaseCommitHash: "basehash",
+					HeadCommitHash: "headhash",
+				})
+				cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), cfg.CDKRoot)
+				contexts := map[string]string{"env": "stg", "pr": "12"}
+				if test.listStacks != nil {
+					cdkClient.EXPECT().List(ctx, cdkPath, contexts).Return(test.listStacks, nil)
+				}
+				if test.expectedStacks != nil {
+					cdkClient.EXPECT().Destroy(ctx, cdkPath, test.expectedStacks, contexts).Return("result", test.destroyError)
+				}
+			}
+			if test.expectedComment != "" {
+				platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+			}
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.DestroyPreview(ctx))
+		})
+	}
+}
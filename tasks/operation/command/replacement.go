@@ -0,0 +1,67 @@
+package command
+
+import (
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"slices"
+	"strings"
+)
+
+const blockedDeployHeader = "### cdk deploy (blocked)\n"
+
+// replacedResource is a stateful resource which is replaced or deleted by deploying
+type replacedResource struct {
+	stack  string
+	change cdk.ResourceChange
+}
+
+// replacedStatefulResources returns stateful resources of the target replaced or deleted in the diffs
+// except the ones whose logical IDs are in allowReplace. Resources retained by the removal policy are not included.
+func replacedStatefulResources(stackDiffs []cdk.StackDiff, target *config.Target, allowReplace []string) []replacedResource {
+	replaced := []replacedResource{}
+	for _, stackDiff := range stackDiffs {
+		for _, change := range stackDiff.Resources {
+			if !change.IsReplacedOrRemoved() || change.Impact == cdk.ImpactOrphan {
+				continue
+			}
+			if !target.IsResourceStateful(change.ResourceType) || slices.Contains(allowReplace, change.LogicalID) {
+				continue
+			}
+			replaced = append(replaced, replacedResource{stack: stackDiff.Stack, change: change})
+		}
+	}
+	return replaced
+}
+
+// blockedDeployComment shows the replaced resources and how to acknowledge them
+func blockedDeployComment(replaced []replacedResource) string {
+	lines := []string{
+		blockedDeployHeader + "Replacement or deletion of the following stateful resources is not acknowledged.",
+		"",
+		"| Stack | Logical ID | Type | Change |",
+		"| --- | --- | --- | --- |",
+	}
+	flags := []string{}
+	for _, resource := range replaced {
+		change := string(resource.change.Impact)
+		if change == "" {
+			change = string(resource.change.Type)
+		}
+		lines = append(lines, fmt.Sprintf(
+			"| %s | %s | %s | %s |",
+			resource.stack,
+			resource.change.LogicalID,
+			resource.change.ResourceType,
+			change,
+		))
+		flags = append(flags, allowReplacePrefix+resource.change.LogicalID)
+	}
+	lines = append(lines, "", fmt.Sprintf(
+		"To deploy them anyway, run /deploy with `%s` or add the `%s` label to the PR.",
+		strings.Join(flags, " "),
+		constant.LabelAllowReplace.Name,
+	))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplacedStatefulResources(t *testing.T) {
+	stackDiffs := []cdk.StackDiff{
+		{
+			Stack: "Stack1",
+			Resources: []cdk.ResourceChange{
+				{Type: cdk.ChangeTypeUpdate, ResourceType: "AWS::RDS::DBInstance", LogicalID: "Db", Impact: cdk.ImpactReplace},
+				{Type: cdk.ChangeTypeUpdate, ResourceType: "AWS::RDS::DBCluster", LogicalID: "Cluster", Impact: cdk.ImpactMayReplace},
+				{Type: cdk.ChangeTypeUpdate, ResourceType: "AWS::RDS::DBInstance", LogicalID: "Db2"},
+				{Type: cdk.ChangeTypeAdd, ResourceType: "AWS::S3::Bucket", LogicalID: "Bucket"},
+			},
+		},
+		{
+			Stack: "Stack2",
+			Resources: []cdk.ResourceChange{
+				{Type: cdk.ChangeTypeRemove, ResourceType: "AWS::S3::Bucket", LogicalID: "Logs", Impact: cdk.ImpactOrphan},
+				{Type: cdk.ChangeTypeRemove, ResourceType: "AWS::DynamoDB::Table", LogicalID: "Table", Impact: cdk.ImpactDestroy},
+				{Type: cdk.ChangeTypeRemove, ResourceType: "AWS::Lambda::Function", LogicalID: "Fn", Impact: cdk.ImpactDestroy},
+			},
+		},
+	}
+	tests := []struct {
+		title          string
+		inTarget       config.Target
+		inAllowReplace []string
+		out            []replacedResource
+	}{
+		{
+			title:    "default_resource_types",
+			inTarget: config.Target{},
+			out: []replacedResource{
+				{stack: "Stack1", change: stackDiffs[0].Resources[0]},
+				{stack: "Stack1", change: stackDiffs[0].Resources[1]},
+				{stack: "Stack2", change: stackDiffs[1].Resources[1]},
+			},
+		},
+		{
+			title:          "allowed",
+			inTarget:       config.Target{},
+			inAllowReplace: []string{"Db", "Table"},
+			out: []replacedResource{
+				{stack: "Stack1", change: stackDiffs[0].Resources[1]},
+			},
+		},
+		{
+			title:    "configured_resource_types",
+			inTarget: config.Target{StatefulResourceTypes: []string{"AWS::DynamoDB::*"}},
+			out: []replacedResource{
+				{stack: "Stack2", change: stackDiffs[1].Resources[1]},
+			},
+		},
+		{
+			title:    "disabled",
+			inTarget: config.Target{StatefulResourceTypes: []string{}},
+			out:      []replacedResource{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.out, replacedStatefulResources(stackDiffs, &test.inTarget, test.inAllowReplace))
+		})
+	}
+}
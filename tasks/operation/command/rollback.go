@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"strings"
 )
 
 // Rollback runs cdk deploy at base branch
@@ -12,7 +13,7 @@ func (r *Runner) Rollback(
 	userName string,
 	stacks []string,
 ) error {
-	return r.updateStatus(ctx, func() (*resultState, error) {
+	return r.updateStatus(ctx, "rollback", func() (*resultState, error) {
 		cdkPath, cfg, target, pr, err := r.setup(ctx, false)
 		if err != nil {
 			return nil, err
@@ -26,22 +27,42 @@ func (r *Runner) Rollback(
 		if _, ok := pr.Labels[constant.LabelDeployed.Name]; !ok {
 			return newResultState(constant.StateNotMergeReady, "PR is not deployed"), nil
 		}
-		if len(stacks) == 0 {
-			stacks, err = r.cdk.List(cdkPath, target.Contexts)
-			if err != nil {
+		var unmatched []string
+		stacks, unmatched, err = r.resolveStacks(ctx, cdkPath, stacks, target.Contexts)
+		if err != nil {
+			return nil, err
+		}
+		if len(unmatched) != 0 {
+			return newResultState(constant.StateNotMergeReady, fmt.Sprintf("No stacks match %s", strings.Join(unmatched, " "))), nil
+		}
+		deployCtx, stopWatchingCancel, err := r.watchCancel(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		result, deployErr := r.cdk.Deploy(deployCtx, cdkPath, stacks, nil, target.Contexts)
+		// cdk may finish just before it is canceled
+		if canceledBy := stopWatchingCancel(); canceledBy != "" && deployErr != nil {
+			output := fmt.Sprintf("%s\n%s", result, deployErr.Error())
+			comment := fmt.Sprintf("### cdk deploy (rollback)\n```\n%s\n```\n%s", output, cancelReport(canceledBy, stacks, output))
+			// only finished stacks are recorded as rolled back
+			if finished, _ := splitFinishedStacks(stacks, output); len(finished) != 0 {
+				comment += "\n" + stacksRecord(finished, pr.BaseCommitHash)
+			}
+			if err := r.platform.CreateComment(ctx, comment); err != nil {
 				return nil, err
 			}
+			return newResultState(constant.StateNotMergeReady, fmt.Sprintf("Canceled by %s", canceledBy)).withDetail(output, nil, cfg.CDKRoot), nil
 		}
-		result, deployErr := r.cdk.Deploy(cdkPath, stacks, target.Contexts)
 		message := "Rollback is completed."
 		var (
+			diff    string
 			hasDiff bool
 			diffErr error
 		)
 		if deployErr != nil {
 			message = deployErr.Error()
 		} else {
-			_, hasDiff, diffErr = r.cdk.Diff(cdkPath, nil, target.Contexts)
+			diff, hasDiff, diffErr = r.cdk.Diff(ctx, cdkPath, nil, target.Contexts)
 			if diffErr != nil {
 				message = diffErr.Error()
 			} else if hasDiff {
@@ -50,18 +71,19 @@ func (r *Runner) Rollback(
 		}
 		if err := r.platform.CreateComment(
 			ctx,
-			fmt.Sprintf("### cdk deploy (rollback)\n```\n%s\n%s\n```", result, message),
+			fmt.Sprintf("### cdk deploy (rollback)\n```\n%s\n%s\n```\n%s", result, message, stacksRecord(stacks, pr.BaseCommitHash)),
 		); err != nil {
 			return nil, err
 		}
+		output := fmt.Sprintf("%s\n%s", result, message)
 		if deployErr != nil || diffErr != nil {
-			return newResultState(constant.StateNotMergeReady, "Fix codes"), nil
+			return newResultState(constant.StateNotMergeReady, "Fix codes").withDetail(output, nil, cfg.CDKRoot), nil
 		}
 		if !hasDiff {
 			if err := r.platform.RemoveLabel(ctx, constant.LabelDeployed); err != nil {
 				return nil, err
 			}
 		}
-		return newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed"), nil
+		return newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed").withDetail(output, changedStacks(diff), cfg.CDKRoot), nil
 	})
 }
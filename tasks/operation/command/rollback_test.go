@@ -14,8 +14,8 @@ import (
 	"testing"
 
 	"errors"
-	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 func TestRunner_Rollback(t *testing.T) {
@@ -73,7 +73,7 @@ func TestRunner_Rollback(t *testing.T) {
 			labels:        map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
 			resultHasDiff: false,
 			expected: expected{
-				comment:  "### cdk deploy (rollback)\n```\nresult\nRollback is completed.\n```",
+				comment:  "### cdk deploy (rollback)\n```\nresult\nRollback is completed.\n```\nStacks: Stack1 Stack2 at basehash",
 				outState: newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed"),
 				isError:  false,
 			},
@@ -96,7 +96,7 @@ func TestRunner_Rollback(t *testing.T) {
 			labels:        map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
 			resultHasDiff: true,
 			expected: expected{
-				comment:  "### cdk deploy (rollback)\n```\nresult\nTo be continued.\n```",
+				comment:  "### cdk deploy (rollback)\n```\nresult\nTo be continued.\n```\nStacks: Stack1 Stack2 at basehash",
 				outState: newResultState(constant.StateNotMergeReady, "Run /deploy after reviewed"),
 				isError:  false,
 			},
@@ -119,7 +119,7 @@ func TestRunner_Rollback(t *testing.T) {
 			labels:      map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
 			deployError: errors.New("cdk deploy error"),
 			expected: expected{
-				comment:  "### cdk deploy (rollback)\n```\nresult\ncdk deploy error\n```",
+				comment:  "### cdk deploy (rollback)\n```\nresult\ncdk deploy error\n```\nStacks: Stack1 Stack2 at basehash",
 				outState: newResultState(constant.StateNotMergeReady, "Fix codes"),
 				isError:  false,
 			},
@@ -142,7 +142,7 @@ func TestRunner_Rollback(t *testing.T) {
 			labels:     map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
 			diffError:  errors.New("cdk diff error"),
 			expected: expected{
-				comment:  "### cdk deploy (rollback)\n```\nresult\ncdk diff error\n```",
+				comment:  "### cdk deploy (rollback)\n```\nresult\ncdk diff error\n```\nStacks: Stack1 Stack2 at basehash",
 				outState: newResultState(constant.StateNotMergeReady, "Fix codes"),
 				isError:  false,
 			},
@@ -247,15 +247,17 @@ func TestRunner_Rollback(t *testing.T) {
 			}
 		}
 
-		cdkPath := fmt.Sprintf("%s/%s", clonePath, cfg.CDKRoot)
+		cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath(baseBranch), cfg.CDKRoot)
 		if len(stacks) == 0 {
 			stacks = []string{"Stack1", "Stack2"}
-			cdkClient.EXPECT().List(cdkPath, target.Contexts).Return(stacks, nil)
+			cdkClient.EXPECT().List(ctx, cdkPath, target.Contexts).Return(stacks, nil)
 		}
 		result := "result"
-		cdkClient.EXPECT().Deploy(cdkPath, stacks, target.Contexts).Return(result, deployError)
+		platformClient.EXPECT().ListComments(ctx).Return([]platform.Comment{}, nil)
+		// ctx which is canceled by /cancel is passed
+		cdkClient.EXPECT().Deploy(gomock.Any(), cdkPath, stacks, nil, target.Contexts).Return(result, deployError)
 		if deployError == nil {
-			cdkClient.EXPECT().Diff(cdkPath, nil, target.Contexts).Return("", resultHasDiff, diffError)
+			cdkClient.EXPECT().Diff(ctx, cdkPath, nil, target.Contexts).Return("", resultHasDiff, diffError)
 		}
 		platformClient.EXPECT().CreateComment(ctx, expected.comment)
 		if deployError != nil || diffError != nil {
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Status comments the labels, the target, the stacks deployed from the PR
+// and whether other deployed PR blocks deploying
+func (r *Runner) Status(ctx context.Context) error {
+	cfg, pr, err := r.readBaseConfig(ctx)
+	if err != nil {
+		return err
+	}
+	comments, err := r.platform.ListComments(ctx)
+	if err != nil {
+		return err
+	}
+	openPRs, err := r.platform.GetOpenPullRequests(ctx)
+	if err != nil {
+		return err
+	}
+	botUserName, err := r.platform.GetUserName(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"### cdkbot status", ""}
+	labels := []string{}
+	for name := range pr.Labels {
+		labels = append(labels, fmt.Sprintf("`%s`", name))
+	}
+	sort.Strings(labels)
+	if len(labels) == 0 {
+		labels = append(labels, "none")
+	}
+	lines = append(lines, fmt.Sprintf("Labels: %s", strings.Join(labels, " ")))
+	if _, ok := cfg.Targets[pr.BaseBranch]; ok {
+		lines = append(lines, fmt.Sprintf("Target: `%s`", pr.BaseBranch))
+	} else {
+		lines = append(lines, fmt.Sprintf("Target: No targets are matched with the base branch `%s`", pr.BaseBranch))
+	}
+	if number, exists := existsOtherDeployedSameBasePRs(openPRs, pr); exists {
+		lines = append(lines, fmt.Sprintf("Blocked: deployed PR #%d is still opened. First /deploy and merge it, or /rollback.", number))
+	} else {
+		lines = append(lines, "Blocked: no")
+	}
+	lines = append(lines, "")
+
+	deployed := deployedStacks(comments, botUserName)
+	if len(deployed) == 0 {
+		lines = append(lines, "No stacks are deployed from this PR.")
+		return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+	}
+	stacks := []string{}
+	for stack := range deployed {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	lines = append(lines, "| Deployed stack | Commit |", "| --- | --- |")
+	for _, stack := range stacks {
+		lines = append(lines, fmt.Sprintf("| %s | %s |", stack, deployed[stack]))
+	}
+	return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+}
+
+// A line of deploy and rollback comments to record which stacks are deployed at which commit
+var stacksRecordFormat = regexp.MustCompile(`(?m)^Stacks: (.+) at (\S+)$`)
+
+func stacksRecord(stacks []string, commitHash string) string {
+	return fmt.Sprintf("Stacks: %s at %s", strings.Join(stacks, " "), commitHash)
+}
+
+// deployedStacks returns the map of stacks deployed from the PR to the head commit.
+// Stacks rolled back or destroyed afterwards are not included.
+// Only comments by cdkbot itself are read because anyone can write the records.
+func deployedStacks(comments []platform.Comment, botUserName string) map[string]string {
+	botComments := []platform.Comment{}
+	for _, comment := range comments {
+		if comment.User == botUserName {
+			botComments = append(botComments, comment)
+		}
+	}
+	return recordedStacks(botComments, "### cdk deploy\n", "### cdk deploy (rollback)\n", "### cdk destroy\n")
+}
+
+// recordedStacks returns the map of stacks recorded in comments starting with addedHeader
+// to the commit hash. Stacks recorded afterwards in comments starting with removedHeaders are not included.
+func recordedStacks(comments []platform.Comment, addedHeader string, removedHeaders ...string) map[string]string {
+	stacks := map[string]string{}
+	for _, comment := range comments {
+		removed := false
+		for _, header := range removedHeaders {
+			if strings.HasPrefix(comment.Body, header) {
+				removed = true
+			}
+		}
+		if !removed && !strings.HasPrefix(comment.Body, addedHeader) {
+			continue
+		}
+		// the record is the last line not to be confused with cdk output
+		matches := stacksRecordFormat.FindAllStringSubmatch(comment.Body, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		match := matches[len(matches)-1]
+		for _, stack := range strings.Fields(match[1]) {
+			if removed {
+				delete(stacks, stack)
+			} else {
+				stacks[stack] = match[2]
+			}
+		}
+	}
+	return stacks
+}
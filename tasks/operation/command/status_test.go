@@ -0,0 +1,109 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_Status(t *testing.T) {
+	deployedLabels := map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed}
+	tests := []struct {
+		title           string
+		labels          map[string]constant.Label
+		comments        []platform.Comment
+		openPRs         []platform.PullRequest
+		expectedComment string
+	}{
+		{
+			title:  "deployed",
+			labels: deployedLabels,
+			comments: []platform.Comment{
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 Stack2 at hash1", User: "cdkbot"},
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack2 at hash2", User: "cdkbot"},
+				// written by another user
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack3 at hash3", User: "sambaiz"},
+			},
+			openPRs: []platform.PullRequest{
+				{Number: 1, BaseBranch: "develop", Labels: deployedLabels},
+			},
+			expectedComment: "### cdkbot status\n\nLabels: `cdkbot:deployed`\nTarget: `develop`\nBlocked: no\n\n" +
+				"| Deployed stack | Commit |\n| --- | --- |\n| Stack1 | hash1 |\n| Stack2 | hash2 |",
+		},
+		{
+			title:  "blocked_and_rolled_back",
+			labels: map[string]constant.Label{},
+			comments: []platform.Comment{
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack1 at hash1", User: "cdkbot"},
+				{Body: "### cdk deploy (rollback)\n```\nresult\nRollback is completed.\n```\nStacks: Stack1 at basehash", User: "cdkbot"},
+			},
+			openPRs: []platform.PullRequest{
+				{Number: 1, BaseBranch: "develop"},
+				{Number: 2, BaseBranch: "develop", Labels: deployedLabels},
+			},
+			expectedComment: "### cdkbot status\n\nLabels: none\nTarget: `develop`\n" +
+				"Blocked: deployed PR #2 is still opened. First /deploy and merge it, or /rollback.\n\nNo stacks are deployed from this PR.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+
+			pr := &platform.PullRequest{
+				Number:         1,
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+				Labels:         test.labels,
+			}
+			clonePath := (&Runner{}).clonePath(pr.BaseBranch)
+			platformClient.EXPECT().GetPullRequest(ctx).Return(pr, nil)
+			gitClient.EXPECT().Clone(clonePath, &pr.BaseCommitHash).Return(nil)
+			configClient.EXPECT().Read(fmt.Sprintf("%s/cdkbot.yml", clonePath)).Return(&config.Config{
+				Targets: map[string]config.Target{"develop": {}},
+			}, nil)
+			platformClient.EXPECT().ListComments(ctx).Return(test.comments, nil)
+			platformClient.EXPECT().GetOpenPullRequests(ctx).Return(test.openPRs, nil)
+			platformClient.EXPECT().GetUserName(ctx).Return("cdkbot", nil)
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Run(ctx, "/status", "user"))
+		})
+	}
+}
+
+func TestDeployedStacks(t *testing.T) {
+	comments := []platform.Comment{
+		{Body: "### cdk diff\n```\nStacks: Stack0 at hash0\n```", User: "cdkbot"},
+		{Body: "### cdk deploy\n```\nStacks: Stack9 at output\n\n```\nStacks: Stack1 Stack2 at hash1", User: "cdkbot"},
+		{Body: "### cdk deploy (rollback)\n```\nresult\n\n```\nStacks: Stack2 at basehash", User: "cdkbot"},
+		{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Preview-1 at hash2", User: "cdkbot"},
+		{Body: "### cdk destroy\n```\nresult\n\n```\nStacks: Preview-1 at hash2", User: "cdkbot"},
+		{Body: "### cdk deploy (preview)\n```\nresult\n\n```\nStacks: Preview at hash3", User: "cdkbot"},
+		// written by another user
+		{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack3 at hash4", User: "sambaiz"},
+		// comments before the record is added
+		{Body: "### cdk deploy\n```\nresult\n\n```", User: "cdkbot"},
+	}
+	assert.Equal(t, map[string]string{"Stack1": "hash1"}, deployedStacks(comments, "cdkbot"))
+}
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+)
+
+const (
+	// Templates longer than this are uploaded or truncated
+	maxCommentTemplateLength = 10000
+	// Total length of templates in a comment not to exceed the comment size limit
+	maxCommentTemplatesLength = 50000
+)
+
+// Synth runs cdk synth and comments the templates.
+// Long templates are uploaded if the platform supports it, otherwise they are truncated.
+func (r *Runner) Synth(
+	ctx context.Context,
+	stacks []string,
+) error {
+	cdkPath, _, target, _, err := r.setup(ctx, true)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return r.platform.CreateComment(ctx, "No targets are matched")
+	}
+	templates, synthErr := r.cdk.Synth(ctx, cdkPath, stacks, target.Contexts)
+	if synthErr != nil {
+		return r.platform.CreateComment(ctx, fmt.Sprintf("### cdk synth\n```\n%s\n```", synthErr.Error()))
+	}
+	if len(templates) == 0 {
+		return r.platform.CreateComment(ctx, "### cdk synth\nNo templates are synthesized.")
+	}
+
+	names := []string{}
+	for stack := range templates {
+		names = append(names, stack)
+	}
+	sort.Strings(names)
+	lines := []string{"### cdk synth"}
+	commentedLength := 0
+	for _, stack := range names {
+		template := strings.TrimRight(templates[stack], "\n")
+		if len(template) > maxCommentTemplateLength || commentedLength+len(template) > maxCommentTemplatesLength {
+			if line, ok := r.uploadTemplate(ctx, stack, template); ok {
+				lines = append(lines, line)
+				continue
+			}
+			size := len(template)
+			template = truncateTemplate(template, min(maxCommentTemplateLength, maxCommentTemplatesLength-commentedLength))
+			if template == "" {
+				lines = append(lines, fmt.Sprintf("- %s: The template is too long to comment (%d bytes).", stack, size))
+				continue
+			}
+			template += fmt.Sprintf("\n... (truncated from %d bytes. Run cdk synth to see the whole template.)", size)
+		}
+		commentedLength += len(template)
+		lines = append(lines, fmt.Sprintf("<details><summary>%s</summary>\n\n```json\n%s\n```\n\n</details>", stack, template))
+	}
+	return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+}
+
+// uploadTemplate uploads the template and returns the line linking to it.
+// It returns false if the platform doesn't support uploading or it fails.
+func (r *Runner) uploadTemplate(ctx context.Context, stack string, template string) (string, bool) {
+	uploader, ok := r.platform.(platform.FileUploader)
+	if !ok {
+		return "", false
+	}
+	// stacks in stages are named such as "Stage/Stack"
+	name := fmt.Sprintf("%s.template.json", strings.ReplaceAll(stack, "/", "-"))
+	url, err := uploader.UploadFile(ctx, name, template)
+	if err != nil {
+		r.logger.Error("upload template error", zap.Error(err))
+		return "", false
+	}
+	return fmt.Sprintf("- %s: [%s](%s)", stack, name, url), true
+}
+
+// truncateTemplate returns the first n bytes of the template without breaking a multi-byte character
+func truncateTemplate(template string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(template) <= n {
+		return template
+	}
+	return strings.ToValidUTF8(template[:n], "")
+}
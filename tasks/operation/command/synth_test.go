@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cdkMock "github.com/sambaiz/cdkbot/tasks/operation/cdk/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+type fileUploaderClient struct {
+	*platformMock.MockClienter
+	uploaded map[string]string
+}
+
+func (c *fileUploaderClient) UploadFile(ctx context.Context, name string, content string) (string, error) {
+	c.uploaded[name] = content
+	return "https://example.com/" + name, nil
+}
+
+func TestRunner_Synth(t *testing.T) {
+	longTemplate := fmt.Sprintf(`{"Description": "%s"}`, strings.Repeat("a", maxCommentTemplateLength))
+	tests := []struct {
+		title            string
+		inStacks         []string
+		templates        map[string]string
+		synthErr         error
+		uploadable       bool
+		expectedComment  string
+		expectedUploaded map[string]string
+	}{
+		{
+			title:    "short_templates",
+			inStacks: []string{"Stack1", "Stack2"},
+			templates: map[string]string{
+				"Stack2": "{}\n",
+				"Stack1": `{"Resources": {}}`,
+			},
+			expectedComment: "### cdk synth\n" +
+				"<details><summary>Stack1</summary>\n\n```json\n{\"Resources\": {}}\n```\n\n</details>\n" +
+				"<details><summary>Stack2</summary>\n\n```json\n{}\n```\n\n</details>",
+		},
+		{
+			title: "long_template_is_uploaded",
+			templates: map[string]string{
+				"Stack1": longTemplate,
+				"Stack2": "{}",
+			},
+			uploadable: true,
+			expectedComment: "### cdk synth\n" +
+				"- Stack1: [Stack1.template.json](https://example.com/Stack1.template.json)\n" +
+				"<details><summary>Stack2</summary>\n\n```json\n{}\n```\n\n</details>",
+			expectedUploaded: map[string]string{"Stack1.template.json": longTemplate},
+		},
+		{
+			title: "long_template_is_truncated",
+			templates: map[string]string{
+				"Stack1": longTemplate,
+			},
+			expectedComment: fmt.Sprintf(
+				"### cdk synth\n<details><summary>Stack1</summary>\n\n```json\n%s\n... (truncated from %d bytes. Run cdk synth to see the whole template.)\n```\n\n</details>",
+				longTemplate[:maxCommentTemplateLength],
+				len(longTemplate),
+			),
+		},
+		{
+			title:           "synth_error",
+			synthErr:        errors.New("cdk synth failed"),
+			expectedComment: "### cdk synth\n```\ncdk synth failed\n```",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+			cdkClient := cdkMock.NewMockClienter(ctrl)
+
+			cfg := config.Config{
+				CDKRoot: ".",
+				Targets: map[string]config.Target{
+					"develop": {Contexts: map[string]string{"env": "stg"}},
+				},
+			}
+			constructSetupMock(ctx, platformClient, gitClient, configClient, cdkClient, true, cfg, &platform.PullRequest{
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+				HeadCommitHash: "headhash",
+			})
+			cdkPath := fmt.Sprintf("%s/%s", (&Runner{}).clonePath("develop"), cfg.CDKRoot)
+			cdkClient.EXPECT().Synth(ctx, cdkPath, test.inStacks, cfg.Targets["develop"].Contexts).Return(test.templates, test.synthErr)
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+
+			uploader := &fileUploaderClient{MockClienter: platformClient, uploaded: map[string]string{}}
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				cdk:      cdkClient,
+				logger:   logger.MockLogger{},
+			}
+			if test.uploadable {
+				runner.platform = uploader
+			}
+			assert.Nil(t, runner.Synth(ctx, test.inStacks))
+			if test.expectedUploaded != nil {
+				assert.Equal(t, test.expectedUploaded, uploader.uploaded)
+			}
+		})
+	}
+}
+
+func TestTruncateTemplate(t *testing.T) {
+	assert.Equal(t, "{}", truncateTemplate("{}", 10))
+	assert.Equal(t, `{"a": "`, truncateTemplate(`{"a": "あ"}`, 8))
+	assert.Equal(t, "", truncateTemplate("{}", 0))
+}
+
+func TestRunner_Synth_BaseConfig(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	platformClient := platformMock.NewMockClienter(ctrl)
+	gitClient := gitMock.NewMockClienter(ctrl)
+	cdkClient := cdkMock.NewMockClienter(ctrl)
+
+	pr := &platform.PullRequest{BaseBranch: "develop", BaseCommitHash: "basehash", HeadCommitHash: "headhash"}
+	runner := constructSetupWithConfigFiles(t, ctx, platformClient, gitClient, cdkClient, pr,
+		"cdkRoot: pr\ntargets:\n  develop:\n    contexts:\n      env: prd\n",
+		"cdkRoot: cdk\ntargets:\n  develop:\n    contexts:\n      env: stg\n",
+	)
+	cdkPath := fmt.Sprintf("%s/cdk", runner.clonePath("develop"))
+	cdkClient.EXPECT().Synth(ctx, cdkPath, []string{"Stack1"}, map[string]string{"env": "stg"}).Return(map[string]string{"Stack1": "{}"}, nil)
+	platformClient.EXPECT().CreateComment(ctx, "### cdk synth\n<details><summary>Stack1</summary>\n\n```json\n{}\n```\n\n</details>").Return(nil)
+	assert.Nil(t, runner.Synth(ctx, []string{"Stack1"}))
+}
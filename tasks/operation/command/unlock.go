@@ -0,0 +1,63 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"sort"
+	"strings"
+)
+
+// Unlock removes the running and deployed labels left by a crashed or abandoned PR.
+// Only users listed in adminUsers are allowed, and who forced it is commented for audit.
+func (r *Runner) Unlock(ctx context.Context, userName string) error {
+	cfg, pr, err := r.readBaseConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.IsUserAdmin(userName) {
+		return r.platform.CreateComment(ctx, fmt.Sprintf("user %s is not allowed to unlock", userName))
+	}
+	removed := []string{}
+	for _, label := range []constant.Label{constant.LabelRunning, constant.LabelDeployed} {
+		if _, ok := pr.Labels[label.Name]; !ok {
+			continue
+		}
+		if err := r.platform.RemoveLabel(ctx, label); err != nil {
+			return err
+		}
+		removed = append(removed, fmt.Sprintf("`%s`", label.Name))
+	}
+	if len(removed) == 0 {
+		return r.platform.CreateComment(ctx, "Nothing to unlock. Neither running nor deployed label is added.")
+	}
+	comments, err := r.platform.ListComments(ctx)
+	if err != nil {
+		return err
+	}
+	botUserName, err := r.platform.GetUserName(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"### cdkbot unlock",
+		"",
+		fmt.Sprintf("%s forced to remove %s.", userName, strings.Join(removed, " ")),
+	}
+	deployed := deployedStacks(comments, botUserName)
+	if len(deployed) == 0 {
+		lines = append(lines, "No stacks are deployed from this PR.")
+		return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+	}
+	stacks := []string{}
+	for stack := range deployed {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	lines = append(lines, "Stacks deployed from this PR may be out of sync with the base branch:")
+	for _, stack := range stacks {
+		lines = append(lines, fmt.Sprintf("- %s (deployed at %s)", stack, deployed[stack]))
+	}
+	return r.platform.CreateComment(ctx, strings.Join(lines, "\n"))
+}
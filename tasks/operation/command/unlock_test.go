@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/config"
+	configMock "github.com/sambaiz/cdkbot/tasks/operation/config/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	gitMock "github.com/sambaiz/cdkbot/tasks/operation/git/mock"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	platformMock "github.com/sambaiz/cdkbot/tasks/operation/platform/mock"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunner_Unlock(t *testing.T) {
+	tests := []struct {
+		title           string
+		userName        string
+		labels          map[string]constant.Label
+		comments        []platform.Comment
+		expectedRemoved []constant.Label
+		expectedComment string
+	}{
+		{
+			title:           "user_is_not_admin",
+			userName:        "sambaiz",
+			labels:          map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
+			expectedComment: "user sambaiz is not allowed to unlock",
+		},
+		{
+			title:           "not_locked",
+			userName:        "admin",
+			labels:          map[string]constant.Label{constant.LabelOutdatedDiff.Name: constant.LabelOutdatedDiff},
+			expectedComment: "Nothing to unlock. Neither running nor deployed label is added.",
+		},
+		{
+			title:    "deployed",
+			userName: "admin",
+			labels: map[string]constant.Label{
+				constant.LabelRunning.Name:  constant.LabelRunning,
+				constant.LabelDeployed.Name: constant.LabelDeployed,
+			},
+			comments: []platform.Comment{
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack2 Stack1 at hash1", User: "cdkbot"},
+				// written by another user
+				{Body: "### cdk deploy\n```\nresult\n\n```\nStacks: Stack3 at hash2", User: "sambaiz"},
+			},
+			expectedRemoved: []constant.Label{constant.LabelRunning, constant.LabelDeployed},
+			expectedComment: "### cdkbot unlock\n\nadmin forced to remove `cdkbot:running` `cdkbot:deployed`.\n" +
+				"Stacks deployed from this PR may be out of sync with the base branch:\n" +
+				"- Stack1 (deployed at hash1)\n- Stack2 (deployed at hash1)",
+		},
+		{
+			title:           "running",
+			userName:        "admin",
+			labels:          map[string]constant.Label{constant.LabelRunning.Name: constant.LabelRunning},
+			expectedRemoved: []constant.Label{constant.LabelRunning},
+			expectedComment: "### cdkbot unlock\n\nadmin forced to remove `cdkbot:running`.\nNo stacks are deployed from this PR.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			platformClient := platformMock.NewMockClienter(ctrl)
+			gitClient := gitMock.NewMockClienter(ctrl)
+			configClient := configMock.NewMockReaderer(ctrl)
+
+			pr := &platform.PullRequest{
+				Number:         1,
+				BaseBranch:     "develop",
+				BaseCommitHash: "basehash",
+				Labels:         test.labels,
+			}
+			clonePath := (&Runner{}).clonePath(pr.BaseBranch)
+			platformClient.EXPECT().GetPullRequest(ctx).Return(pr, nil)
+			gitClient.EXPECT().Clone(clonePath, &pr.BaseCommitHash).Return(nil)
+			configClient.EXPECT().Read(fmt.Sprintf("%s/cdkbot.yml", clonePath)).Return(&config.Config{
+				Targets:    map[string]config.Target{"develop": {}},
+				AdminUsers: []string{"admin"},
+			}, nil)
+			for _, label := range test.expectedRemoved {
+				platformClient.EXPECT().RemoveLabel(ctx, label).Return(nil)
+			}
+			if len(test.expectedRemoved) != 0 {
+				platformClient.EXPECT().ListComments(ctx).Return(test.comments, nil)
+				platformClient.EXPECT().GetUserName(ctx).Return("cdkbot", nil)
+			}
+			platformClient.EXPECT().CreateComment(ctx, test.expectedComment).Return(nil)
+
+			runner := &Runner{
+				platform: platformClient,
+				git:      gitClient,
+				config:   configClient,
+				logger:   logger.MockLogger{},
+			}
+			assert.Nil(t, runner.Run(ctx, "/unlock", test.userName))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"path"
+	"strconv"
 )
 
 // Readerer is interface of config reader
@@ -19,11 +21,53 @@ type Config struct {
 	Targets     map[string]Target `yaml:"targets"`
 	PreCommands []string          `yaml:"preCommands"`
 	DeployUsers []string          `yaml:"deployUsers"`
+	// Users allowed to run admin commands such as /unlock
+	AdminUsers []string `yaml:"adminUsers"`
+	// Directory of policy rule files from the repository root. Rule files of the base branch are used.
+	PolicyDir string `yaml:"policyDir"`
 }
 
 // Target is cdkbot target
 type Target struct {
 	Contexts map[string]string `yaml:"contexts"`
+	// Patterns of stacks allowed to be destroyed such as "Preview-*"
+	DestroyableStacks []string `yaml:"destroyableStacks"`
+	// Context keys allowed to be overridden by commands such as "/deploy -c key=value"
+	OverridableContexts []string `yaml:"overridableContexts"`
+	// Preview environments deployed for each PR. Disabled if nil.
+	Preview *Preview `yaml:"preview"`
+	// Patterns of resource types such as "AWS::RDS::*" whose replacement or deletion blocks /deploy.
+	// defaultStatefulResourceTypes are used if not specified, and nothing blocks if it is empty.
+	StatefulResourceTypes []string `yaml:"statefulResourceTypes"`
+	// Number of approvals on the head commit by users other than the author required to /deploy
+	RequiredApprovals int `yaml:"requiredApprovals"`
+}
+
+// Preview is the setting of preview environments
+type Preview struct {
+	// Stacks deployed as the preview. All stacks are deployed if empty.
+	Stacks []string `yaml:"stacks"`
+	// Key of the context to pass the PR number. Default is "pr".
+	ContextKey string `yaml:"contextKey"`
+}
+
+// Default key of the context to pass the PR number
+const defaultPreviewContextKey = "pr"
+
+// Resource types whose data is lost by replacement or deletion
+var defaultStatefulResourceTypes = []string{
+	"AWS::Cognito::UserPool",
+	"AWS::DynamoDB::GlobalTable",
+	"AWS::DynamoDB::Table",
+	"AWS::EFS::FileSystem",
+	"AWS::ElastiCache::CacheCluster",
+	"AWS::ElastiCache::ReplicationGroup",
+	"AWS::Elasticsearch::Domain",
+	"AWS::KMS::Key",
+	"AWS::OpenSearchService::Domain",
+	"AWS::RDS::DBCluster",
+	"AWS::RDS::DBInstance",
+	"AWS::S3::Bucket",
 }
 
 // Read config
@@ -51,3 +95,84 @@ func (c *Config) IsUserAllowedDeploy(userName string) bool {
 	}
 	return false
 }
+
+// IsUserAllowedDestroy returns whether user is allowed to destroy.
+// Unlike deploying, only users listed in deployUsers are allowed.
+func (c *Config) IsUserAllowedDestroy(userName string) bool {
+	for _, user := range c.DeployUsers {
+		if user == userName {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUserAdmin returns whether user is listed in adminUsers
+func (c *Config) IsUserAdmin(userName string) bool {
+	for _, user := range c.AdminUsers {
+		if user == userName {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStackDestroyable returns whether the stack matches any of destroyableStacks patterns
+func (t *Target) IsStackDestroyable(stack string) bool {
+	for _, pattern := range t.DestroyableStacks {
+		if matched, err := path.Match(pattern, stack); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsResourceStateful returns whether the resource type matches any of statefulResourceTypes patterns
+func (t *Target) IsResourceStateful(resourceType string) bool {
+	patterns := t.StatefulResourceTypes
+	if patterns == nil {
+		patterns = defaultStatefulResourceTypes
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, resourceType); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContextOverridable returns whether the context key is listed in overridableContexts
+func (t *Target) IsContextOverridable(key string) bool {
+	for _, overridable := range t.OverridableContexts {
+		if overridable == key {
+			return true
+		}
+	}
+	return false
+}
+
+// OverriddenContexts returns contexts of the target overridden by overrides
+func (t *Target) OverriddenContexts(overrides map[string]string) map[string]string {
+	contexts := map[string]string{}
+	for k, v := range t.Contexts {
+		contexts[k] = v
+	}
+	for k, v := range overrides {
+		contexts[k] = v
+	}
+	return contexts
+}
+
+// PreviewContexts returns contexts of the target with the PR number added for the preview
+func (t *Target) PreviewContexts(number int) map[string]string {
+	contexts := map[string]string{}
+	for k, v := range t.Contexts {
+		contexts[k] = v
+	}
+	key := defaultPreviewContextKey
+	if t.Preview != nil && t.Preview.ContextKey != "" {
+		key = t.Preview.ContextKey
+	}
+	contexts[key] = strconv.Itoa(number)
+	return contexts
+}
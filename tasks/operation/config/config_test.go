@@ -23,15 +23,24 @@ func TestReaderRead(t *testing.T) {
 						Contexts: map[string]string{
 							"env": "stg",
 						},
+						OverridableContexts: []string{"version"},
+						DestroyableStacks:   []string{"Preview-*"},
+						Preview: &Preview{
+							Stacks: []string{"Preview"},
+						},
 					},
 					"master": {
 						Contexts: map[string]string{
 							"env": "prd",
 						},
+						StatefulResourceTypes: []string{"AWS::RDS::*", "AWS::DynamoDB::Table"},
+						RequiredApprovals:     2,
 					},
 				},
 				PreCommands: []string{"npm run build"},
 				DeployUsers: []string{"sambaiz"},
+				AdminUsers:  []string{"admin"},
+				PolicyDir:   "policies",
 			},
 		},
 		{
@@ -73,3 +82,98 @@ func TestConfigIsIncludedInDeployUsers(t *testing.T) {
 		assert.False(t, cfg.IsUserAllowedDeploy("foobar"))
 	})
 }
+
+func TestConfigIsUserAllowedDestroy(t *testing.T) {
+	t.Run("no deploy_users are specified", func(t *testing.T) {
+		cfg := Config{
+			DeployUsers: nil,
+		}
+		assert.False(t, cfg.IsUserAllowedDestroy("foobar"))
+	})
+	t.Run("deploy_users are specified", func(t *testing.T) {
+		cfg := Config{
+			DeployUsers: []string{"sambaiz"},
+		}
+		assert.True(t, cfg.IsUserAllowedDestroy("sambaiz"))
+		assert.False(t, cfg.IsUserAllowedDestroy("foobar"))
+	})
+}
+
+func TestConfigIsUserAdmin(t *testing.T) {
+	t.Run("no admin_users are specified", func(t *testing.T) {
+		cfg := Config{
+			AdminUsers: nil,
+		}
+		assert.False(t, cfg.IsUserAdmin("foobar"))
+	})
+	t.Run("admin_users are specified", func(t *testing.T) {
+		cfg := Config{
+			AdminUsers: []string{"admin"},
+		}
+		assert.True(t, cfg.IsUserAdmin("admin"))
+		assert.False(t, cfg.IsUserAdmin("foobar"))
+	})
+}
+
+func TestTargetIsStackDestroyable(t *testing.T) {
+	target := Target{
+		DestroyableStacks: []string{"Preview-*", "Sandbox"},
+	}
+	assert.True(t, target.IsStackDestroyable("Preview-123"))
+	assert.True(t, target.IsStackDestroyable("Sandbox"))
+	assert.False(t, target.IsStackDestroyable("Sandbox2"))
+	assert.False(t, target.IsStackDestroyable("Production"))
+	assert.False(t, (&Target{}).IsStackDestroyable("Preview-123"))
+}
+
+func TestTargetIsResourceStateful(t *testing.T) {
+	target := Target{
+		StatefulResourceTypes: []string{"AWS::RDS::*", "AWS::DynamoDB::Table"},
+	}
+	assert.True(t, target.IsResourceStateful("AWS::RDS::DBCluster"))
+	assert.True(t, target.IsResourceStateful("AWS::DynamoDB::Table"))
+	assert.False(t, target.IsResourceStateful("AWS::S3::Bucket"))
+	// default
+	assert.True(t, (&Target{}).IsResourceStateful("AWS::S3::Bucket"))
+	assert.False(t, (&Target{}).IsResourceStateful("AWS::Lambda::Function"))
+	// disabled
+	assert.False(t, (&Target{StatefulResourceTypes: []string{}}).IsResourceStateful("AWS::S3::Bucket"))
+}
+
+func TestTargetIsContextOverridable(t *testing.T) {
+	target := Target{
+		OverridableContexts: []string{"version"},
+	}
+	assert.True(t, target.IsContextOverridable("version"))
+	assert.False(t, target.IsContextOverridable("env"))
+	assert.False(t, (&Target{}).IsContextOverridable("version"))
+}
+
+func TestTargetOverriddenContexts(t *testing.T) {
+	target := Target{
+		Contexts: map[string]string{"env": "stg", "version": "1"},
+	}
+	assert.Equal(
+		t,
+		map[string]string{"env": "stg", "version": "2"},
+		target.OverriddenContexts(map[string]string{"version": "2"}),
+	)
+	assert.Equal(t, map[string]string{"env": "stg", "version": "1"}, target.Contexts)
+}
+
+func TestTargetPreviewContexts(t *testing.T) {
+	t.Run("default_context_key", func(t *testing.T) {
+		target := Target{
+			Contexts: map[string]string{"env": "stg"},
+			Preview:  &Preview{},
+		}
+		assert.Equal(t, map[string]string{"env": "stg", "pr": "12"}, target.PreviewContexts(12))
+		assert.Equal(t, map[string]string{"env": "stg"}, target.Contexts)
+	})
+	t.Run("context_key_is_specified", func(t *testing.T) {
+		target := Target{
+			Preview: &Preview{ContextKey: "preview"},
+		}
+		assert.Equal(t, map[string]string{"preview": "12"}, target.PreviewContexts(12))
+	})
+}
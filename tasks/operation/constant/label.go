@@ -30,10 +30,17 @@ var (
 		Description: "Some stacks are deployed. Complete /deploy and merge, or /rollback them.",
 		Color:       "a2eeef",
 	}
+	// LabelAllowReplace expresses replacement and deletion of stateful resources are acknowledged on the PR
+	LabelAllowReplace = Label{
+		Name:        fmt.Sprintf("%sallow replace", labelPrefix),
+		Description: "Replacement and deletion of stateful resources are acknowledged.",
+		Color:       "d93f0b",
+	}
 	// NameToLabel is map of label's name to label
 	NameToLabel = map[string]Label{
 		LabelOutdatedDiff.Name: LabelOutdatedDiff,
 		LabelRunning.Name:      LabelRunning,
 		LabelDeployed.Name:     LabelDeployed,
+		LabelAllowReplace.Name: LabelAllowReplace,
 	}
 )
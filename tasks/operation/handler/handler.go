@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/bitbucket"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/gitea"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/gitlab"
+)
+
+// Handle passes the webhook request to the handler of the platform
+func Handle(
+	ctx context.Context,
+	platform string,
+	req events.APIGatewayProxyRequest,
+	logger logger.Loggerer,
+) error {
+	switch platform {
+	case "github":
+		return github.Handler(ctx, req, logger)
+	case "gitlab":
+		return gitlab.Handler(ctx, req, logger)
+	case "bitbucket":
+		return bitbucket.Handler(ctx, req, logger)
+	case "gitea":
+		return gitea.Handler(ctx, req, logger)
+	default:
+		return fmt.Errorf("unknown platform %s is setted", platform)
+	}
+}
+
+// MessageGroupID returns the group where the webhook is processed one by one.
+// Empty is returned if nothing is to do for the webhook.
+func MessageGroupID(
+	ctx context.Context,
+	platform string,
+	req events.APIGatewayProxyRequest,
+) (string, error) {
+	switch platform {
+	case "github":
+		return github.MessageGroupID(ctx, req)
+	case "gitlab":
+		return gitlab.MessageGroupID(ctx, req)
+	case "bitbucket":
+		return bitbucket.MessageGroupID(ctx, req)
+	case "gitea":
+		return gitea.MessageGroupID(ctx, req)
+	default:
+		return "", fmt.Errorf("unknown platform %s is setted", platform)
+	}
+}
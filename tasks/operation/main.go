@@ -2,15 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/sambaiz/cdkbot/tasks/operation/handler"
 	"github.com/sambaiz/cdkbot/tasks/operation/logger"
-	"github.com/sambaiz/cdkbot/tasks/operation/platform/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"github.com/sambaiz/cdkbot/tasks/operation/worker"
 	"os"
+	"strconv"
 
 	"github.com/aws/aws-lambda-go/events"
 	"go.uber.org/zap"
@@ -19,45 +20,11 @@ import (
 func main() {
 	logger := logger.New()
 	sess := session.New()
-	sqsSvc := sqs.New(sess)
-	for {
-		res, err := sqsSvc.ReceiveMessage(&sqs.ReceiveMessageInput{
-			QueueUrl:            aws.String(os.Getenv("OPERATION_QUEUE_URL")),
-			MaxNumberOfMessages: aws.Int64(1),
-		})
-		if err != nil {
-			logger.Error("receive message error", zap.Error(err))
-			break
-		}
-
-		if len(res.Messages) == 0 {
-			break
-		}
-
-		msg := res.Messages[0]
-		if _, err := sqsSvc.DeleteMessage(&sqs.DeleteMessageInput{
-			QueueUrl:      aws.String(os.Getenv("OPERATION_QUEUE_URL")),
-			ReceiptHandle: msg.ReceiptHandle,
-		}); err != nil {
-			logger.Error("delete message error", zap.Error(err))
-			break
-		}
-		var req events.APIGatewayProxyRequest
-		if err := json.Unmarshal([]byte(*msg.Body), &req); err != nil {
-			logger.Error("unmarshal error", zap.Error(err))
-			continue
-		}
-
-		ctx := context.Background()
-		switch os.Getenv("PLATFORM") {
-		case "github":
-			err = github.Handler(ctx, req, logger)
-		default:
-			err = fmt.Errorf("unknown platform %s is setted", os.Getenv("PLATFORM"))
-		}
-		if err != nil {
-			logger.Error("operation error", zap.Error(err))
-		}
+	q := queue.NewSQS(sqs.New(sess), os.Getenv("OPERATION_QUEUE_URL"))
+	if err := worker.New(q, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		return handler.Handle(ctx, os.Getenv("PLATFORM"), req, logger)
+	}, logger).Run(context.Background(), concurrency(), true); err != nil {
+		logger.Error("worker error", zap.Error(err))
 	}
 
 	ecsSvc := ecs.New(sess)
@@ -69,3 +36,12 @@ func main() {
 		logger.Error("shutdown task error", zap.Error(err))
 	}
 }
+
+// concurrency returns OPERATION_CONCURRENCY or 1 if it is not set or invalid
+func concurrency() int {
+	n, err := strconv.Atoi(os.Getenv("OPERATION_CONCURRENCY"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
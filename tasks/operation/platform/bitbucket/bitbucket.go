@@ -0,0 +1,273 @@
+package bitbucket
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/command"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/bitbucket/client"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"golang.org/x/xerrors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type repository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+type actor struct {
+	Name string `json:"name"`
+}
+
+type pullRequest struct {
+	ID    int `json:"id"`
+	ToRef struct {
+		DisplayID  string     `json:"displayId"`
+		Repository repository `json:"repository"`
+	} `json:"toRef"`
+}
+
+type pullRequestEvent struct {
+	Actor       actor       `json:"actor"`
+	PullRequest pullRequest `json:"pullRequest"`
+}
+
+// pullRequestClosedEvent is sent when the PR is merged or declined
+type pullRequestClosedEvent pullRequestEvent
+
+type commentEvent struct {
+	Actor       actor       `json:"actor"`
+	PullRequest pullRequest `json:"pullRequest"`
+	Comment     struct {
+		Text string `json:"text"`
+	} `json:"comment"`
+}
+
+type refsChangedEvent struct {
+	Repository repository `json:"repository"`
+	Changes    []struct {
+		Ref struct {
+			DisplayID string `json:"displayId"`
+			Type      string `json:"type"`
+		} `json:"ref"`
+		Type string `json:"type"`
+	} `json:"changes"`
+}
+
+// Handler handles Bitbucket Server webhook
+func Handler(
+	ctx context.Context,
+	req events.APIGatewayProxyRequest,
+	logger logger.Loggerer,
+) error {
+	hook, err := parse(req)
+	if err != nil {
+		return err
+	}
+
+	switch ev := hook.(type) {
+	case *pullRequestEvent:
+		repo := ev.PullRequest.ToRef.Repository
+		cloneURL, err := constructCloneURL(repo)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(
+			client.New(ctx, repo.Project.Key, repo.Slug, ev.PullRequest.ID),
+			cloneURL,
+			logger,
+		)
+		if err := runner.Diff(ctx, nil); err != nil {
+			return err
+		}
+		if err := runner.DeployPreview(ctx); err != nil {
+			return err
+		}
+	case *pullRequestClosedEvent:
+		repo := ev.PullRequest.ToRef.Repository
+		cloneURL, err := constructCloneURL(repo)
+		if err != nil {
+			return err
+		}
+		if err := command.NewRunner(
+			client.New(ctx, repo.Project.Key, repo.Slug, ev.PullRequest.ID),
+			cloneURL,
+			logger,
+		).DestroyPreview(ctx); err != nil {
+			return err
+		}
+	case *refsChangedEvent:
+		cloneURL, err := constructCloneURL(ev.Repository)
+		if err != nil {
+			return err
+		}
+		for _, change := range ev.Changes {
+			if change.Ref.Type != "BRANCH" || change.Type == "DELETE" {
+				continue
+			}
+			client, err := client.NewWithHeadBranch(
+				ctx,
+				ev.Repository.Project.Key,
+				ev.Repository.Slug,
+				change.Ref.DisplayID,
+			)
+			if err != nil {
+				// When push to branch where PR is not created, nothing is to do
+				continue
+			}
+			runner := command.NewRunner(client, cloneURL, logger)
+			if err := runner.Diff(ctx, nil); err != nil {
+				return err
+			}
+			// redeploy the preview with the pushed commit
+			if err := runner.DeployPreview(ctx); err != nil {
+				return err
+			}
+		}
+	case *commentEvent:
+		repo := ev.PullRequest.ToRef.Repository
+		cloneURL, err := constructCloneURL(repo)
+		if err != nil {
+			return err
+		}
+		if err := command.NewRunner(
+			client.New(ctx, repo.Project.Key, repo.Slug, ev.PullRequest.ID),
+			cloneURL,
+			logger,
+		).Run(ctx, ev.Comment.Text, ev.Actor.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MessageGroupID returns the group where webhooks are processed one by one,
+// which is keyed by the repository and the target branch of the PR.
+// Empty is returned if nothing is to do for the webhook.
+func MessageGroupID(ctx context.Context, req events.APIGatewayProxyRequest) (string, error) {
+	hook, err := parse(req)
+	if err != nil {
+		return "", err
+	}
+
+	switch ev := hook.(type) {
+	case *pullRequestEvent:
+		return pullRequestGroupID(ev.PullRequest), nil
+	case *pullRequestClosedEvent:
+		return pullRequestGroupID(ev.PullRequest), nil
+	case *refsChangedEvent:
+		for _, change := range ev.Changes {
+			if change.Ref.Type != "BRANCH" || change.Type == "DELETE" {
+				continue
+			}
+			client, err := client.NewWithHeadBranch(
+				ctx,
+				ev.Repository.Project.Key,
+				ev.Repository.Slug,
+				change.Ref.DisplayID,
+			)
+			if err != nil {
+				continue
+			}
+			pr, err := client.GetPullRequest(ctx)
+			if err != nil {
+				return "", err
+			}
+			// Changes of multiple branches are pushed rarely, so the first one is used
+			return queue.GroupID(repositoryName(ev.Repository), pr.BaseBranch), nil
+		}
+		return "", nil
+	case *commentEvent:
+		return pullRequestGroupID(ev.PullRequest), nil
+	}
+	return "", nil
+}
+
+func pullRequestGroupID(pr pullRequest) string {
+	return queue.GroupID(repositoryName(pr.ToRef.Repository), pr.ToRef.DisplayID)
+}
+
+func repositoryName(repo repository) string {
+	return fmt.Sprintf("%s/%s", repo.Project.Key, repo.Slug)
+}
+
+// parse validates the signature and parses the webhook
+func parse(req events.APIGatewayProxyRequest) (interface{}, error) {
+	if err := validateSignature(
+		req.Headers["X-Hub-Signature"],
+		[]byte(req.Body),
+		[]byte(os.Getenv("BITBUCKET_WEBHOOK_SECRET")),
+	); err != nil {
+		return nil, xerrors.Errorf("Signature is invalid: %w", err)
+	}
+	hook, err := parseWebHook(req.Headers["X-Event-Key"], []byte(req.Body))
+	if err != nil {
+		return nil, xerrors.Errorf("parse hook error: %w", err)
+	}
+	return hook, nil
+}
+
+// validateSignature validates "sha256=<HMAC hex digest>" signature of the payload
+func validateSignature(signature string, payload, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("BITBUCKET_WEBHOOK_SECRET is not set")
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("unsupported signature %s", signature)
+	}
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), actual) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func parseWebHook(eventKey string, payload []byte) (interface{}, error) {
+	var event interface{}
+	switch eventKey {
+	case "pr:opened":
+		event = &pullRequestEvent{}
+	case "pr:merged", "pr:declined":
+		event = &pullRequestClosedEvent{}
+	case "pr:comment:added":
+		event = &commentEvent{}
+	case "repo:refs_changed":
+		event = &refsChangedEvent{}
+	default:
+		return nil, fmt.Errorf("unknown X-Event-Key in message: %v", eventKey)
+	}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func constructCloneURL(repo repository) (string, error) {
+	u, err := url.Parse(fmt.Sprintf(
+		"%s/scm/%s/%s.git",
+		strings.TrimRight(os.Getenv("BITBUCKET_BASE_URL"), "/"),
+		strings.ToLower(repo.Project.Key),
+		repo.Slug,
+	))
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(os.Getenv("BITBUCKET_USER_NAME"), os.Getenv("BITBUCKET_ACCESS_TOKEN"))
+	return u.String(), nil
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Client is an implementation of platform.Client
+type Client struct {
+	client     *http.Client
+	baseURL    string
+	token      string
+	projectKey string
+	repoSlug   string
+	number     int
+	// name of the user of the token, which is got lazily
+	userName string
+}
+
+// New Bitbucket Server client
+func New(
+	ctx context.Context,
+	projectKey string,
+	repoSlug string,
+	number int,
+) *Client {
+	return &Client{
+		client:     http.DefaultClient,
+		baseURL:    strings.TrimRight(os.Getenv("BITBUCKET_BASE_URL"), "/"),
+		token:      os.Getenv("BITBUCKET_ACCESS_TOKEN"),
+		projectKey: projectKey,
+		repoSlug:   repoSlug,
+		number:     number,
+	}
+}
+
+// NewWithHeadBranch create Bitbucket Server client with head (from) branch
+func NewWithHeadBranch(
+	ctx context.Context,
+	projectKey string,
+	repoSlug string,
+	headBranch string,
+) (*Client, error) {
+	client := New(ctx, projectKey, repoSlug, 0)
+	var prs []pullRequest
+	if err := client.getAll(
+		ctx,
+		fmt.Sprintf("%s?state=OPEN&direction=OUTGOING&at=%s", client.repoPath("pull-requests"), url.QueryEscape("refs/heads/"+headBranch)),
+		func(values json.RawMessage) error {
+			var paging []pullRequest
+			if err := json.Unmarshal(values, &paging); err != nil {
+				return err
+			}
+			prs = append(prs, paging...)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, fmt.Errorf("PR is not found with head branch %s", headBranch)
+	}
+	client.number = prs[0].ID
+	return client, nil
+}
+
+const (
+	maxPage = 50
+	limit   = 100
+)
+
+func (c *Client) repoPath(path string) string {
+	return fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/%s", url.PathEscape(c.projectKey), url.PathEscape(c.repoSlug), path)
+}
+
+func (c *Client) pullRequestPath(number int, path string) string {
+	if path == "" {
+		return c.repoPath(fmt.Sprintf("pull-requests/%d", number))
+	}
+	return c.repoPath(fmt.Sprintf("pull-requests/%d/%s", number, path))
+}
+
+// APIError is returned when Bitbucket Server API responds with non 2xx status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Bitbucket API error: %d %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	buf, err := c.send(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(buf) == 0 {
+		return nil
+	}
+	return json.Unmarshal(buf, out)
+}
+
+// send requests the API and returns the response body
+func (c *Client) send(ctx context.Context, method, path string, in interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(buf)}
+	}
+	return buf, nil
+}
+
+// GetUserName returns the name of the user of the token, who is cdkbot itself
+func (c *Client) GetUserName(ctx context.Context) (string, error) {
+	if c.userName != "" {
+		return c.userName, nil
+	}
+	// whoami returns the name in plain text
+	buf, err := c.send(ctx, http.MethodGet, "/plugins/servlet/applinks/whoami", nil)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(string(buf))
+	if name == "" {
+		return "", errors.New("user of the access token is not found")
+	}
+	c.userName = name
+	return name, nil
+}
+
+type page struct {
+	Values        json.RawMessage `json:"values"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+// getAll calls f with values of each page
+func (c *Client) getAll(ctx context.Context, path string, f func(values json.RawMessage) error) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	start := 0
+	for i := 0; i < maxPage; i++ {
+		var p page
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s%sstart=%d&limit=%d", path, sep, start, limit), nil, &p); err != nil {
+			return err
+		}
+		if err := f(p.Values); err != nil {
+			return err
+		}
+		if p.IsLastPage {
+			return nil
+		}
+		start = p.NextPageStart
+	}
+	return errors.New("Too many pages")
+}
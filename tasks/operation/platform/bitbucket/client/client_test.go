@@ -0,0 +1,351 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBitbucket is a stand-in of Bitbucket Server API having one repository
+type fakeBitbucket struct {
+	mu           sync.Mutex
+	pullRequests map[int]*pullRequest
+	comments     map[int][]comment
+	nextID       int64
+	statuses     map[string][]map[string]string
+	merged       map[int]string
+}
+
+func newFakeBitbucket() *fakeBitbucket {
+	return &fakeBitbucket{
+		pullRequests: map[int]*pullRequest{},
+		comments:     map[int][]comment{},
+		nextID:       1,
+		statuses:     map[string][]map[string]string{},
+		merged:       map[int]string{},
+	}
+}
+
+func writePage(w http.ResponseWriter, r *http.Request, values interface{}) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	buf, _ := json.Marshal(values)
+	var all []json.RawMessage
+	json.Unmarshal(buf, &all)
+	// return one value per page to test paging
+	p := map[string]interface{}{
+		"values":        []json.RawMessage{},
+		"isLastPage":    start+1 >= len(all),
+		"nextPageStart": start + 1,
+	}
+	if start < len(all) {
+		p["values"] = all[start : start+1]
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	if strings.HasPrefix(r.URL.Path, "/rest/build-status/1.0/commits/") {
+		status := map[string]string{}
+		for k, v := range body {
+			status[k] = v.(string)
+		}
+		hash := strings.TrimPrefix(r.URL.Path, "/rest/build-status/1.0/commits/")
+		f.statuses[hash] = append(f.statuses[hash], status)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.URL.Path == "/plugins/servlet/applinks/whoami" {
+		w.Write([]byte("cdkbot"))
+		return
+	}
+	prefix := "/rest/api/1.0/projects/PRJ/repos/repo/pull-requests"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	if parts[0] == "" {
+		prs := []pullRequest{}
+		for i := 1; i <= len(f.pullRequests); i++ {
+			pr := f.pullRequests[i]
+			if at := r.URL.Query().Get("at"); at != "" && at != pr.FromRef.ID {
+				continue
+			}
+			prs = append(prs, *pr)
+		}
+		writePage(w, r, prs)
+		return
+	}
+	id, _ := strconv.Atoi(parts[0])
+	pr, ok := f.pullRequests[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(pr)
+	case parts[1] == "merge" && r.Method == http.MethodPost:
+		if r.URL.Query().Get("version") != strconv.Itoa(pr.Version) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.merged[id] = body["message"].(string)
+		json.NewEncoder(w).Encode(pr)
+	case parts[1] == "activities" && r.Method == http.MethodGet:
+		activities := []activity{}
+		for i := len(f.comments[id]) - 1; i >= 0; i-- {
+			activities = append(activities, activity{Action: "COMMENTED", CommentAction: "ADDED", Comment: f.comments[id][i]})
+		}
+		activities = append(activities, activity{Action: "OPENED"})
+		writePage(w, r, activities)
+	case parts[1] == "comments" && len(parts) == 2 && r.Method == http.MethodPost:
+		c := comment{ID: f.nextID, Text: body["text"].(string)}
+		c.Author.Name = "cdkbot"
+		f.nextID++
+		f.comments[id] = append(f.comments[id], c)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(c)
+	case parts[1] == "comments" && len(parts) == 3:
+		commentID, _ := strconv.ParseInt(parts[2], 10, 64)
+		for i, c := range f.comments[id] {
+			if c.ID != commentID {
+				continue
+			}
+			switch r.Method {
+			case http.MethodGet:
+				json.NewEncoder(w).Encode(c)
+			case http.MethodPut:
+				if int(body["version"].(float64)) != c.Version {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				f.comments[id][i].Text = body["text"].(string)
+				f.comments[id][i].Version++
+				json.NewEncoder(w).Encode(f.comments[id][i])
+			case http.MethodDelete:
+				if r.URL.Query().Get("version") != strconv.Itoa(c.Version) {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				f.comments[id] = append(f.comments[id][:i], f.comments[id][i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newPullRequest(id int, fromBranch, fromHash string) *pullRequest {
+	pr := &pullRequest{
+		ID:      id,
+		Version: 3,
+		FromRef: ref{ID: "refs/heads/" + fromBranch, DisplayID: fromBranch, LatestCommit: fromHash},
+		ToRef:   ref{ID: "refs/heads/develop", DisplayID: "develop", LatestCommit: "basehash"},
+	}
+	pr.Author.User.Name = "sambaiz"
+	pr.Links.Self = append(pr.Links.Self, struct {
+		Href string `json:"href"`
+	}{Href: "https://bitbucket.example.com/projects/PRJ/repos/repo/pull-requests/" + strconv.Itoa(id)})
+	return pr
+}
+
+func setupClient(t *testing.T) (*Client, *fakeBitbucket) {
+	fake := newFakeBitbucket()
+	fake.pullRequests[1] = newPullRequest(1, "feature", "headhash")
+	fake.pullRequests[2] = newPullRequest(2, "feature2", "headhash2")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return &Client{
+		client:     server.Client(),
+		baseURL:    server.URL,
+		token:      "token",
+		projectKey: "PRJ",
+		repoSlug:   "repo",
+		number:     1,
+	}, fake
+}
+
+func TestNewWithHeadBranch(t *testing.T) {
+	ctx := context.Background()
+	_, fake := setupClient(t)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv("BITBUCKET_BASE_URL", server.URL+"/")
+	t.Setenv("BITBUCKET_ACCESS_TOKEN", "token")
+
+	client, err := NewWithHeadBranch(ctx, "PRJ", "repo", "feature2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.number)
+
+	_, err = NewWithHeadBranch(ctx, "PRJ", "repo", "notfound")
+	assert.NotNil(t, err)
+}
+
+func TestClient_Comment(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+
+	assert.Nil(t, client.CreateComment(ctx, "first"))
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	assert.Nil(t, client.CreateComment(ctx, "second"))
+	comments, err := client.ListComments(ctx)
+	assert.Nil(t, err)
+	// label comment is not included
+	assert.Equal(t, []platform.Comment{{ID: 1, Body: "first", User: "cdkbot"}, {ID: 3, Body: "second", User: "cdkbot"}}, comments)
+
+	assert.Nil(t, client.DeleteComment(ctx, 1))
+	comments, err = client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 3, Body: "second", User: "cdkbot"}}, comments)
+}
+
+func TestClient_Label(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	// nothing to remove
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelRunning))
+	assert.Len(t, fake.comments[1], 0)
+
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelDeployed))
+	assert.Nil(t, client.AddLabelToOtherPR(ctx, constant.LabelOutdatedDiff, 2))
+	assert.Len(t, fake.comments[1], 1)
+	pr, err := client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]constant.Label{
+		constant.LabelRunning.Name:  constant.LabelRunning,
+		constant.LabelDeployed.Name: constant.LabelDeployed,
+	}, pr.Labels)
+
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelRunning))
+	pr, err = client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed}, pr.Labels)
+
+	prs, err := client.GetOpenPullRequests(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.PullRequest{
+		{
+			Number:         1,
+			BaseBranch:     "develop",
+			BaseCommitHash: "basehash",
+			HeadCommitHash: "headhash",
+			Labels:         map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
+			Author:         "sambaiz",
+		},
+		{
+			Number:         2,
+			BaseBranch:     "develop",
+			BaseCommitHash: "basehash",
+			HeadCommitHash: "headhash2",
+			Labels:         map[string]constant.Label{constant.LabelOutdatedDiff.Name: constant.LabelOutdatedDiff},
+			Author:         "sambaiz",
+		},
+	}, prs)
+}
+
+func TestClient_Label_ByOtherUser(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	forged := comment{ID: 100, Text: labelCommentHeader + "- `cdkbot:deployed`: forged"}
+	forged.Author.Name = "attacker"
+	fake.comments[1] = append(fake.comments[1], forged)
+
+	pr, err := client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]constant.Label{}, pr.Labels)
+
+	// a new comment is created instead of editing the comment of the other user
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	assert.Len(t, fake.comments[1], 2)
+	assert.Equal(t, forged, fake.comments[1][0])
+	pr, err = client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]constant.Label{constant.LabelRunning.Name: constant.LabelRunning}, pr.Labels)
+
+	// the comment of the other user is listed as a normal comment
+	comments, err := client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 100, Body: forged.Text, User: "attacker"}}, comments)
+}
+
+func TestClient_ListReviews(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	newParticipant := func(name, status, commit string) participant {
+		p := participant{Status: status, LastReviewedCommit: commit}
+		p.User.Name = name
+		return p
+	}
+	fake.pullRequests[1].Reviewers = []participant{
+		newParticipant("alice", "APPROVED", "headhash"),
+		newParticipant("bob", "UNAPPROVED", ""),
+	}
+	fake.pullRequests[1].Participants = []participant{
+		newParticipant("carol", "NEEDS_WORK", "oldhash"),
+	}
+
+	reviews, err := client.ListReviews(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Review{
+		{User: "alice", Approved: true, CommitHash: "headhash"},
+		{User: "carol", Approved: false, CommitHash: "oldhash"},
+	}, reviews)
+}
+
+func TestClient_MergePullRequest(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	assert.Nil(t, client.MergePullRequest(ctx, "automatically merged by cdkbot"))
+	assert.Equal(t, "automatically merged by cdkbot", fake.merged[1])
+}
+
+func TestClient_SetStatus(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	assert.Nil(t, client.SetStatus(ctx, constant.StateRunning, ""))
+	assert.Nil(t, client.SetStatus(ctx, constant.StateMergeReady, strings.Repeat("a", 300)))
+	assert.Equal(t, []map[string]string{
+		{
+			"state":       "INPROGRESS",
+			"key":         "cdkbot",
+			"name":        "cdkbot",
+			"url":         "https://bitbucket.example.com/projects/PRJ/repos/repo/pull-requests/1",
+			"description": "",
+		},
+		{
+			"state":       "SUCCESSFUL",
+			"key":         "cdkbot",
+			"name":        "cdkbot",
+			"url":         "https://bitbucket.example.com/projects/PRJ/repos/repo/pull-requests/1",
+			"description": strings.Repeat("a", 255),
+		},
+	}, fake.statuses["headhash"])
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type comment struct {
+	ID      int64  `json:"id"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+	Author  struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+type activity struct {
+	Action        string  `json:"action"`
+	CommentAction string  `json:"commentAction"`
+	Comment       comment `json:"comment"`
+}
+
+// CreateComment creates a comment
+func (c *Client) CreateComment(
+	ctx context.Context,
+	body string,
+) error {
+	return c.do(ctx, http.MethodPost, c.pullRequestPath(c.number, "comments"), map[string]string{
+		"text": body,
+	}, nil)
+}
+
+// ListComments gets comments order by posted asc.
+// The comment holding labels is not included.
+func (c *Client) ListComments(
+	ctx context.Context,
+) ([]platform.Comment, error) {
+	comments, err := c.listComments(ctx, c.number)
+	if err != nil {
+		return nil, err
+	}
+	userName, err := c.GetUserName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := []platform.Comment{}
+	for _, comment := range comments {
+		if isLabelComment(comment, userName) {
+			continue
+		}
+		ret = append(ret, platform.Comment{
+			ID:   comment.ID,
+			Body: comment.Text,
+			User: comment.Author.Name,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteComment deletes a comment
+func (c *Client) DeleteComment(
+	ctx context.Context,
+	commentID int64,
+) error {
+	// the latest version is required to delete
+	var current comment
+	if err := c.do(ctx, http.MethodGet, c.pullRequestPath(c.number, fmt.Sprintf("comments/%d", commentID)), nil, &current); err != nil {
+		return err
+	}
+	return c.do(
+		ctx,
+		http.MethodDelete,
+		c.pullRequestPath(c.number, fmt.Sprintf("comments/%d?version=%d", commentID, current.Version)),
+		nil,
+		nil,
+	)
+}
+
+// listComments gets the top-level comments of the PR order by posted asc
+func (c *Client) listComments(ctx context.Context, number int) ([]comment, error) {
+	comments := []comment{}
+	// activities are returned in order of newest first
+	if err := c.getAll(ctx, c.pullRequestPath(number, "activities"), func(values json.RawMessage) error {
+		var activities []activity
+		if err := json.Unmarshal(values, &activities); err != nil {
+			return err
+		}
+		for _, activity := range activities {
+			if activity.Action == "COMMENTED" && activity.CommentAction == "ADDED" {
+				comments = append([]comment{activity.Comment}, comments...)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Bitbucket Server doesn't have labels
+// so they are kept in a comment of cdkbot starting with this header.
+// Comments of other users starting with it are ignored because anyone can write them.
+const labelCommentHeader = "### cdkbot labels\n"
+
+// isLabelComment returns whether the comment holds labels
+func isLabelComment(comment comment, userName string) bool {
+	return comment.Author.Name == userName && strings.HasPrefix(comment.Text, labelCommentHeader)
+}
+
+// AddLabel adds label to PR
+func (c *Client) AddLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	return c.editLabels(ctx, c.number, func(labels map[string]constant.Label) {
+		labels[label.Name] = label
+	})
+}
+
+// AddLabelToOtherPR adds label to other PR
+func (c *Client) AddLabelToOtherPR(
+	ctx context.Context,
+	label constant.Label,
+	number int,
+) error {
+	return c.editLabels(ctx, number, func(labels map[string]constant.Label) {
+		labels[label.Name] = label
+	})
+}
+
+// RemoveLabel removes label from PR
+func (c *Client) RemoveLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	return c.editLabels(ctx, c.number, func(labels map[string]constant.Label) {
+		delete(labels, label.Name)
+	})
+}
+
+// getLabels gets labels of the PR and the comment holding them. The comment is nil if it doesn't exist.
+func (c *Client) getLabels(ctx context.Context, number int) (map[string]constant.Label, *comment, error) {
+	comments, err := c.listComments(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	userName, err := c.GetUserName(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	labels := map[string]constant.Label{}
+	for _, comment := range comments {
+		if !isLabelComment(comment, userName) {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimPrefix(comment.Text, labelCommentHeader), "\n") {
+			parts := strings.Split(line, "`")
+			if len(parts) < 3 {
+				continue
+			}
+			if lb, ok := constant.NameToLabel[parts[1]]; ok {
+				labels[lb.Name] = lb
+			}
+		}
+		return labels, &comment, nil
+	}
+	return labels, nil, nil
+}
+
+func (c *Client) editLabels(ctx context.Context, number int, edit func(labels map[string]constant.Label)) error {
+	labels, labelComment, err := c.getLabels(ctx, number)
+	if err != nil {
+		return err
+	}
+	before := formatLabelComment(labels)
+	edit(labels)
+	text := formatLabelComment(labels)
+	// no need to edit
+	if text == before {
+		return nil
+	}
+	if labelComment == nil {
+		return c.do(ctx, http.MethodPost, c.pullRequestPath(number, "comments"), map[string]string{
+			"text": text,
+		}, nil)
+	}
+	return c.do(ctx, http.MethodPut, c.pullRequestPath(number, fmt.Sprintf("comments/%d", labelComment.ID)), map[string]interface{}{
+		"text":    text,
+		"version": labelComment.Version,
+	}, nil)
+}
+
+func formatLabelComment(labels map[string]constant.Label) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("- `%s`: %s", name, labels[name].Description))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "(none)")
+	}
+	return labelCommentHeader + strings.Join(lines, "\n")
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type ref struct {
+	ID           string `json:"id"`
+	DisplayID    string `json:"displayId"`
+	LatestCommit string `json:"latestCommit"`
+}
+
+type participant struct {
+	User struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	// APPROVED, NEEDS_WORK or UNAPPROVED
+	Status             string `json:"status"`
+	LastReviewedCommit string `json:"lastReviewedCommit"`
+}
+
+type pullRequest struct {
+	ID           int           `json:"id"`
+	Version      int           `json:"version"`
+	FromRef      ref           `json:"fromRef"`
+	ToRef        ref           `json:"toRef"`
+	Author       participant   `json:"author"`
+	Reviewers    []participant `json:"reviewers"`
+	Participants []participant `json:"participants"`
+	Links        struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+func (pr *pullRequest) url() string {
+	if len(pr.Links.Self) == 0 {
+		return ""
+	}
+	return pr.Links.Self[0].Href
+}
+
+// GetPullRequest gets the PR
+func (c *Client) GetPullRequest(ctx context.Context) (*platform.PullRequest, error) {
+	pr, err := c.getPullRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	labels, _, err := c.getLabels(ctx, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &platform.PullRequest{
+		Number:         pr.ID,
+		BaseBranch:     pr.ToRef.DisplayID,
+		BaseCommitHash: pr.ToRef.LatestCommit,
+		HeadCommitHash: pr.FromRef.LatestCommit,
+		Labels:         labels,
+		Author:         pr.Author.User.Name,
+	}, nil
+}
+
+// GetOpenPullRequests gets open PRs
+func (c *Client) GetOpenPullRequests(ctx context.Context) ([]platform.PullRequest, error) {
+	prs := []pullRequest{}
+	if err := c.getAll(ctx, fmt.Sprintf("%s?state=OPEN", c.repoPath("pull-requests")), func(values json.RawMessage) error {
+		var paging []pullRequest
+		if err := json.Unmarshal(values, &paging); err != nil {
+			return err
+		}
+		prs = append(prs, paging...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var ret []platform.PullRequest
+	for _, pr := range prs {
+		labels, _, err := c.getLabels(ctx, pr.ID)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, platform.PullRequest{
+			Number:         pr.ID,
+			BaseBranch:     pr.ToRef.DisplayID,
+			BaseCommitHash: pr.ToRef.LatestCommit,
+			HeadCommitHash: pr.FromRef.LatestCommit,
+			Labels:         labels,
+			Author:         pr.Author.User.Name,
+		})
+	}
+	return ret, nil
+}
+
+// MergePullRequest merges PR
+func (c *Client) MergePullRequest(ctx context.Context, message string) error {
+	pr, err := c.getPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+	return c.do(
+		ctx,
+		http.MethodPost,
+		c.pullRequestPath(c.number, fmt.Sprintf("merge?version=%d", pr.Version)),
+		map[string]string{
+			"message": message,
+		},
+		nil,
+	)
+}
+
+func (c *Client) getPullRequest(ctx context.Context) (*pullRequest, error) {
+	var pr pullRequest
+	if err := c.do(ctx, http.MethodGet, c.pullRequestPath(c.number, ""), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
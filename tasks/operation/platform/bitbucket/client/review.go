@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+)
+
+// ListReviews gets the current approvals and change requests of reviewers and participants.
+// Bitbucket Server keeps only the latest status of each user.
+func (c *Client) ListReviews(ctx context.Context) ([]platform.Review, error) {
+	pr, err := c.getPullRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := []platform.Review{}
+	for _, p := range append(pr.Reviewers, pr.Participants...) {
+		if p.Status != "APPROVED" && p.Status != "NEEDS_WORK" {
+			continue
+		}
+		ret = append(ret, platform.Review{
+			User:       p.User.Name,
+			Approved:   p.Status == "APPROVED",
+			CommitHash: p.LastReviewedCommit,
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+)
+
+var stateMap = map[constant.State]string{
+	constant.StateMergeReady:    "SUCCESSFUL",
+	constant.StateNotMergeReady: "FAILED",
+	constant.StateRunning:       "INPROGRESS",
+	constant.StateError:         "FAILED",
+}
+
+var statusKey = "cdkbot"
+
+// SetStatus set build status of latest commit
+func (c *Client) SetStatus(
+	ctx context.Context,
+	state constant.State,
+	description string,
+) error {
+	pr, err := c.getPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	// maximum is 255 characters
+	if len(description) > 255 {
+		description = description[:255]
+	}
+
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/rest/build-status/1.0/commits/%s", pr.FromRef.LatestCommit), map[string]string{
+		"state":       stateMap[state],
+		"key":         statusKey,
+		"name":        statusKey,
+		"url":         pr.url(),
+		"description": description,
+	}, nil)
+}
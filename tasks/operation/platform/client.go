@@ -9,6 +9,8 @@ import (
 type Comment struct {
 	ID   int64
 	Body string
+	// login name of the author
+	User string
 }
 
 // PullRequest is a PR
@@ -18,6 +20,17 @@ type PullRequest struct {
 	BaseCommitHash string
 	HeadCommitHash string
 	Labels         map[string]constant.Label
+	// login name of the author
+	Author string
+}
+
+// Review is an approval or a change request of PR
+type Review struct {
+	// login name of the reviewer
+	User     string
+	Approved bool
+	// Commit the review is submitted on
+	CommitHash string
 }
 
 // Clienter is interface of platform client
@@ -48,10 +61,41 @@ type Clienter interface {
 	) error
 	GetPullRequest(ctx context.Context) (*PullRequest, error)
 	GetOpenPullRequests(ctx context.Context) ([]PullRequest, error)
+	// GetUserName returns the login name of cdkbot itself
+	GetUserName(ctx context.Context) (string, error)
 	MergePullRequest(ctx context.Context, message string) error
+	// ListReviews gets approvals and change requests order by submitted asc.
+	// Dismissed ones and comments are not included.
+	ListReviews(ctx context.Context) ([]Review, error)
 	SetStatus(
 		ctx context.Context,
 		state constant.State,
 		description string,
 	) error
 }
+
+// Result is the detailed result of a command
+type Result struct {
+	// diff, deploy or rollback
+	Command     string
+	State       constant.State
+	Description string
+	// Output of cdk
+	Output string
+	// Stacks having differences
+	ChangedStacks []string
+	// Relative path of the directory where cdk.json exists
+	CDKRoot string
+}
+
+// ResultReporter is interface of platform client which can report the detailed result.
+// If a Clienter also implements it, ReportResult is called instead of SetStatus.
+type ResultReporter interface {
+	ReportResult(ctx context.Context, result Result) error
+}
+
+// FileUploader is interface of platform client which can store a file too long to comment
+type FileUploader interface {
+	// UploadFile stores the content and returns the URL to see it
+	UploadFile(ctx context.Context, name string, content string) (string, error)
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Client is an implementation of platform.Client
+type Client struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	owner   string
+	repo    string
+	number  int
+	// cache of GetUserName
+	userName string
+}
+
+// New Gitea client
+func New(
+	ctx context.Context,
+	owner string,
+	repo string,
+	number int,
+) *Client {
+	return &Client{
+		client:  http.DefaultClient,
+		baseURL: fmt.Sprintf("%s/api/v1", strings.TrimRight(os.Getenv("GITEA_BASE_URL"), "/")),
+		token:   os.Getenv("GITEA_ACCESS_TOKEN"),
+		owner:   owner,
+		repo:    repo,
+		number:  number,
+	}
+}
+
+// NewWithHeadBranch create Gitea client with head branch
+func NewWithHeadBranch(
+	ctx context.Context,
+	owner string,
+	repo string,
+	headBranch string,
+) (*Client, error) {
+	client := New(ctx, owner, repo, 0)
+	// Gitea's list API can't filter PRs by head branch
+	prs, err := client.listOpenPullRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.Head.Ref == headBranch {
+			client.number = pr.Number
+			return client, nil
+		}
+	}
+	return nil, fmt.Errorf("PR is not found with head branch %s", headBranch)
+}
+
+const (
+	maxPage = 50
+	perPage = 50
+)
+
+// GetUserName returns the name of the user of the token, who is cdkbot itself
+func (c *Client) GetUserName(ctx context.Context) (string, error) {
+	if c.userName != "" {
+		return c.userName, nil
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	c.userName = user.Login
+	return user.Login, nil
+}
+
+func (c *Client) repoPath(path string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", c.owner, c.repo, path)
+}
+
+// APIError is returned when Gitea API responds with non 2xx status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Gitea API error: %d %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(buf)}
+	}
+	if out == nil || len(buf) == 0 {
+		return nil
+	}
+	return json.Unmarshal(buf, out)
+}
@@ -0,0 +1,327 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitea is a stand-in of Gitea API having one repository
+type fakeGitea struct {
+	mu           sync.Mutex
+	pullRequests map[int]*pullRequest
+	comments     []comment
+	reviews      []review
+	labels       []label
+	statuses     map[string][]map[string]string
+	merged       map[int]map[string]string
+	// status responded to removing a label from the PR if it is set
+	removeLabelStatus int
+	// number of times labels are edited
+	labelPatches int
+}
+
+func newFakeGitea() *fakeGitea {
+	return &fakeGitea{
+		pullRequests: map[int]*pullRequest{},
+		statuses:     map[string][]map[string]string{},
+		merged:       map[int]map[string]string{},
+	}
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "token token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/api/v1/user" {
+		w.Write([]byte(`{"login": "cdkbot"}`))
+		return
+	}
+	prefix := "/api/v1/repos/owner/repo/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	switch {
+	case parts[0] == "pulls" && len(parts) == 1:
+		prs := []pullRequest{}
+		if page <= 1 {
+			for i := 1; i <= len(f.pullRequests); i++ {
+				prs = append(prs, *f.pullRequests[i])
+			}
+		}
+		json.NewEncoder(w).Encode(prs)
+	case parts[0] == "pulls" && len(parts) == 2:
+		number, _ := strconv.Atoi(parts[1])
+		json.NewEncoder(w).Encode(f.pullRequests[number])
+	case parts[0] == "pulls" && parts[2] == "reviews":
+		if page > 1 {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode(f.reviews)
+	case parts[0] == "pulls" && parts[2] == "merge":
+		number, _ := strconv.Atoi(parts[1])
+		f.merged[number] = map[string]string{"Do": body["Do"].(string), "MergeMessageField": body["MergeMessageField"].(string)}
+	case parts[0] == "issues" && parts[1] == "comments":
+		id, _ := strconv.ParseInt(parts[2], 10, 64)
+		comments := []comment{}
+		for _, c := range f.comments {
+			if c.ID != id {
+				comments = append(comments, c)
+			}
+		}
+		f.comments = comments
+		w.WriteHeader(http.StatusNoContent)
+	case parts[0] == "issues" && parts[2] == "comments" && r.Method == http.MethodGet:
+		// ignore paging parameters like old Gitea
+		json.NewEncoder(w).Encode(f.comments)
+	case parts[0] == "issues" && parts[2] == "comments" && r.Method == http.MethodPost:
+		c := comment{ID: int64(len(f.comments) + 1), Body: body["body"].(string)}
+		c.User.Login = "cdkbot"
+		f.comments = append(f.comments, c)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(c)
+	case parts[0] == "issues" && parts[2] == "labels":
+		number, _ := strconv.Atoi(parts[1])
+		pr := f.pullRequests[number]
+		if r.Method == http.MethodPost {
+			for _, id := range body["labels"].([]interface{}) {
+				for _, lb := range f.labels {
+					if lb.ID == int64(id.(float64)) {
+						pr.Labels = append(pr.Labels, lb)
+					}
+				}
+			}
+		} else {
+			if f.removeLabelStatus != 0 {
+				w.WriteHeader(f.removeLabelStatus)
+				return
+			}
+			id, _ := strconv.ParseInt(parts[3], 10, 64)
+			labels := []label{}
+			for _, lb := range pr.Labels {
+				if lb.ID != id {
+					labels = append(labels, lb)
+				}
+			}
+			if len(labels) == len(pr.Labels) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			pr.Labels = labels
+		}
+		json.NewEncoder(w).Encode(pr.Labels)
+	case parts[0] == "labels" && len(parts) == 1 && r.Method == http.MethodGet:
+		if page > 1 {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode(f.labels)
+	case parts[0] == "labels" && len(parts) == 1 && r.Method == http.MethodPost:
+		// Gitea stores the color without "#"
+		lb := label{ID: int64(len(f.labels) + 1), Name: body["name"].(string), Color: strings.TrimPrefix(body["color"].(string), "#"), Description: body["description"].(string)}
+		f.labels = append(f.labels, lb)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(lb)
+	case parts[0] == "labels" && len(parts) == 2 && r.Method == http.MethodPatch:
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		for i, lb := range f.labels {
+			if lb.ID == id {
+				f.labels[i].Color = strings.TrimPrefix(body["color"].(string), "#")
+				f.labels[i].Description = body["description"].(string)
+			}
+		}
+		f.labelPatches++
+	case parts[0] == "statuses":
+		status := map[string]string{}
+		for k, v := range body {
+			status[k] = v.(string)
+		}
+		f.statuses[parts[1]] = append(f.statuses[parts[1]], status)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func setupClient(t *testing.T) (*Client, *fakeGitea) {
+	fake := newFakeGitea()
+	fake.pullRequests[1] = &pullRequest{
+		Number: 1,
+		Base:   branch{Ref: "develop", SHA: "basehash"},
+		Head:   branch{Ref: "feature", SHA: "headhash"},
+	}
+	fake.pullRequests[1].User.Login = "sambaiz"
+	fake.pullRequests[2] = &pullRequest{
+		Number: 2,
+		Base:   branch{Ref: "develop", SHA: "basehash"},
+		Head:   branch{Ref: "feature2", SHA: "headhash2"},
+		Labels: []label{{ID: 10, Name: "other"}},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return &Client{
+		client:  server.Client(),
+		baseURL: server.URL + "/api/v1",
+		token:   "token",
+		owner:   "owner",
+		repo:    "repo",
+		number:  1,
+	}, fake
+}
+
+func TestNewWithHeadBranch(t *testing.T) {
+	ctx := context.Background()
+	_, fake := setupClient(t)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv("GITEA_BASE_URL", server.URL+"/")
+	t.Setenv("GITEA_ACCESS_TOKEN", "token")
+
+	client, err := NewWithHeadBranch(ctx, "owner", "repo", "feature2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.number)
+
+	_, err = NewWithHeadBranch(ctx, "owner", "repo", "notfound")
+	assert.NotNil(t, err)
+}
+
+func TestClient_Comment(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+
+	assert.Nil(t, client.CreateComment(ctx, "first"))
+	assert.Nil(t, client.CreateComment(ctx, "second"))
+	comments, err := client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 1, Body: "first", User: "cdkbot"}, {ID: 2, Body: "second", User: "cdkbot"}}, comments)
+
+	assert.Nil(t, client.DeleteComment(ctx, 1))
+	comments, err = client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 2, Body: "second", User: "cdkbot"}}, comments)
+}
+
+func TestClient_Label(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	fake.labels = []label{{ID: 1, Name: constant.LabelDeployed.Name, Color: "000000"}}
+
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	assert.Nil(t, client.AddLabelToOtherPR(ctx, constant.LabelDeployed, 2))
+	// existing label is updated
+	assert.Equal(t, constant.LabelDeployed.Color, fake.labels[0].Color)
+	assert.Equal(t, label{
+		ID:          2,
+		Name:        constant.LabelRunning.Name,
+		Color:       constant.LabelRunning.Color,
+		Description: constant.LabelRunning.Description,
+	}, fake.labels[1])
+
+	prs, err := client.GetOpenPullRequests(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.PullRequest{
+		{
+			Number:         1,
+			BaseBranch:     "develop",
+			BaseCommitHash: "basehash",
+			HeadCommitHash: "headhash",
+			Labels:         map[string]constant.Label{constant.LabelRunning.Name: constant.LabelRunning},
+			Author:         "sambaiz",
+		},
+		{
+			Number:         2,
+			BaseBranch:     "develop",
+			BaseCommitHash: "basehash",
+			HeadCommitHash: "headhash2",
+			Labels:         map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed},
+		},
+	}, prs)
+
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelRunning))
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelOutdatedDiff))
+	pr, err := client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]constant.Label{}, pr.Labels)
+	// the label is not added to the PR
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelRunning))
+
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	// labels not changed are not edited
+	assert.Equal(t, 1, fake.labelPatches)
+	fake.removeLabelStatus = http.StatusInternalServerError
+	assert.Equal(t, &APIError{StatusCode: http.StatusInternalServerError, Body: ""}, client.RemoveLabel(ctx, constant.LabelRunning))
+}
+
+func TestClient_GetUserName(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+
+	name, err := client.GetUserName(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "cdkbot", name)
+}
+
+func TestClient_ListReviews(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	newReview := func(id int64, user, state, commitID string, dismissed bool) review {
+		r := review{ID: id, State: state, CommitID: commitID, Dismissed: dismissed}
+		r.User.Login = user
+		return r
+	}
+	fake.reviews = []review{
+		newReview(1, "alice", "APPROVED", "headhash", false),
+		newReview(2, "bob", "COMMENT", "headhash", false),
+		newReview(3, "carol", "APPROVED", "oldhash", true),
+		newReview(4, "carol", "REQUEST_CHANGES", "headhash", false),
+	}
+
+	reviews, err := client.ListReviews(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Review{
+		{User: "alice", Approved: true, CommitHash: "headhash"},
+		{User: "carol", Approved: false, CommitHash: "headhash"},
+	}, reviews)
+}
+
+func TestClient_MergePullRequest(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	assert.Nil(t, client.MergePullRequest(ctx, "automatically merged by cdkbot"))
+	assert.Equal(t, map[string]string{"Do": "merge", "MergeMessageField": "automatically merged by cdkbot"}, fake.merged[1])
+}
+
+func TestClient_SetStatus(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	assert.Nil(t, client.SetStatus(ctx, constant.StateError, strings.Repeat("a", 300)))
+	assert.Equal(t, []map[string]string{
+		{
+			"state":       "error",
+			"context":     "cdkbot",
+			"description": strings.Repeat("a", 255),
+		},
+	}, fake.statuses["headhash"])
+}
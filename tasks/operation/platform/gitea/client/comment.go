@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// CreateComment creates a comment
+func (c *Client) CreateComment(
+	ctx context.Context,
+	body string,
+) error {
+	return c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("issues/%d/comments", c.number)), map[string]string{
+		"body": body,
+	}, nil)
+}
+
+// ListComments gets comments order by posted asc
+func (c *Client) ListComments(
+	ctx context.Context,
+) ([]platform.Comment, error) {
+	page := 1
+	comments := []comment{}
+	for true {
+		var paging []comment
+		if err := c.do(
+			ctx,
+			http.MethodGet,
+			c.repoPath(fmt.Sprintf("issues/%d/comments?page=%d&limit=%d", c.number, page, perPage)),
+			nil,
+			&paging,
+		); err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		comments = append(comments, paging...)
+		// Old Gitea ignores paging parameters and returns all comments
+		if len(paging) < perPage {
+			break
+		}
+		page++
+		if page > maxPage {
+			return nil, errors.New("Too many comments")
+		}
+	}
+	ret := []platform.Comment{}
+	for _, comment := range comments {
+		ret = append(ret, platform.Comment{
+			ID:   comment.ID,
+			Body: comment.Body,
+			User: comment.User.Login,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteComment deletes a comment
+func (c *Client) DeleteComment(
+	ctx context.Context,
+	commentID int64,
+) error {
+	return c.do(ctx, http.MethodDelete, c.repoPath(fmt.Sprintf("issues/comments/%d", commentID)), nil, nil)
+}
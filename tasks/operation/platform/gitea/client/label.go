@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+	"strings"
+)
+
+type label struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// AddLabel adds label to PR
+func (c *Client) AddLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	return c.addLabel(ctx, c.number, label)
+}
+
+// AddLabelToOtherPR adds label to other PR
+func (c *Client) AddLabelToOtherPR(
+	ctx context.Context,
+	label constant.Label,
+	number int,
+) error {
+	return c.addLabel(ctx, number, label)
+}
+
+// RemoveLabel removes label from PR
+func (c *Client) RemoveLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	lb, err := c.findLabel(ctx, label.Name)
+	if err != nil {
+		return err
+	}
+	if lb == nil {
+		return nil
+	}
+	err = c.do(ctx, http.MethodDelete, c.repoPath(fmt.Sprintf("issues/%d/labels/%d", c.number, lb.ID)), nil, nil)
+	// the label is not added to the PR
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) addLabel(ctx context.Context, number int, lb constant.Label) error {
+	// Gitea identifies labels with not name but ID
+	id, err := c.ensureLabel(ctx, lb)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("issues/%d/labels", number)), map[string][]int64{
+		"labels": {id},
+	}, nil)
+}
+
+// ensureLabel creates the label or updates it if the color or description is different, and returns the ID
+func (c *Client) ensureLabel(ctx context.Context, lb constant.Label) (int64, error) {
+	color := fmt.Sprintf("#%s", lb.Color)
+	current, err := c.findLabel(ctx, lb.Name)
+	if err != nil {
+		return 0, err
+	}
+	if current == nil {
+		var created label
+		if err := c.do(ctx, http.MethodPost, c.repoPath("labels"), label{
+			Name:        lb.Name,
+			Color:       color,
+			Description: lb.Description,
+		}, &created); err != nil {
+			return 0, err
+		}
+		return created.ID, nil
+	}
+	// no need to edit. Gitea returns the color without "#".
+	if strings.EqualFold(strings.TrimPrefix(current.Color, "#"), lb.Color) && current.Description == lb.Description {
+		return current.ID, nil
+	}
+	if err := c.do(ctx, http.MethodPatch, c.repoPath(fmt.Sprintf("labels/%d", current.ID)), label{
+		Name:        lb.Name,
+		Color:       color,
+		Description: lb.Description,
+	}, nil); err != nil {
+		return 0, err
+	}
+	return current.ID, nil
+}
+
+// findLabel finds repository's label by name. It returns nil if not found.
+func (c *Client) findLabel(ctx context.Context, name string) (*label, error) {
+	page := 1
+	for true {
+		var paging []label
+		if err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("labels?page=%d&limit=%d", page, perPage)), nil, &paging); err != nil {
+			return nil, err
+		}
+		for _, lb := range paging {
+			if lb.Name == name {
+				return &lb, nil
+			}
+		}
+		if len(paging) < perPage {
+			break
+		}
+		page++
+		if page > maxPage {
+			return nil, errors.New("Too many labels")
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type branch struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+type pullRequest struct {
+	Number int     `json:"number"`
+	Base   branch  `json:"base"`
+	Head   branch  `json:"head"`
+	Labels []label `json:"labels"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+func (pr *pullRequest) toPullRequest() platform.PullRequest {
+	labels := map[string]constant.Label{}
+	for _, label := range pr.Labels {
+		if lb, ok := constant.NameToLabel[label.Name]; ok {
+			labels[lb.Name] = lb
+		}
+	}
+	return platform.PullRequest{
+		Number:         pr.Number,
+		BaseBranch:     pr.Base.Ref,
+		BaseCommitHash: pr.Base.SHA,
+		HeadCommitHash: pr.Head.SHA,
+		Labels:         labels,
+		Author:         pr.User.Login,
+	}
+}
+
+// GetPullRequest gets the PR
+func (c *Client) GetPullRequest(ctx context.Context) (*platform.PullRequest, error) {
+	var pr pullRequest
+	if err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("pulls/%d", c.number)), nil, &pr); err != nil {
+		return nil, err
+	}
+	ret := pr.toPullRequest()
+	return &ret, nil
+}
+
+// GetOpenPullRequests gets open PRs
+func (c *Client) GetOpenPullRequests(ctx context.Context) ([]platform.PullRequest, error) {
+	prs, err := c.listOpenPullRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ret []platform.PullRequest
+	for _, pr := range prs {
+		ret = append(ret, pr.toPullRequest())
+	}
+	return ret, nil
+}
+
+// MergePullRequest merges PR
+func (c *Client) MergePullRequest(ctx context.Context, message string) error {
+	return c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("pulls/%d/merge", c.number)), map[string]string{
+		"Do":                "merge",
+		"MergeMessageField": message,
+	}, nil)
+}
+
+func (c *Client) listOpenPullRequests(ctx context.Context) ([]pullRequest, error) {
+	page := 1
+	prs := []pullRequest{}
+	for true {
+		var paging []pullRequest
+		if err := c.do(
+			ctx,
+			http.MethodGet,
+			c.repoPath(fmt.Sprintf("pulls?state=open&page=%d&limit=%d", page, perPage)),
+			nil,
+			&paging,
+		); err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		prs = append(prs, paging...)
+		page++
+		if page > maxPage {
+			return nil, fmt.Errorf("Too many PRs")
+		}
+	}
+	return prs, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type review struct {
+	ID    int64  `json:"id"`
+	State string `json:"state"`
+	User  struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	CommitID  string `json:"commit_id"`
+	Dismissed bool   `json:"dismissed"`
+}
+
+// ListReviews gets approvals and change requests order by submitted asc
+func (c *Client) ListReviews(ctx context.Context) ([]platform.Review, error) {
+	page := 1
+	reviews := []review{}
+	for true {
+		var paging []review
+		if err := c.do(
+			ctx,
+			http.MethodGet,
+			c.repoPath(fmt.Sprintf("pulls/%d/reviews?page=%d&limit=%d", c.number, page, perPage)),
+			nil,
+			&paging,
+		); err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		reviews = append(reviews, paging...)
+		if len(paging) < perPage {
+			break
+		}
+		page++
+		if page > maxPage {
+			return nil, errors.New("Too many reviews")
+		}
+	}
+	ret := []platform.Review{}
+	for _, review := range reviews {
+		// COMMENT, PENDING and REQUEST_REVIEW are not reviews deciding the PR
+		if review.Dismissed || (review.State != "APPROVED" && review.State != "REQUEST_CHANGES") {
+			continue
+		}
+		ret = append(ret, platform.Review{
+			User:       review.User.Login,
+			Approved:   review.State == "APPROVED",
+			CommitHash: review.CommitID,
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+)
+
+var stateMap = map[constant.State]string{
+	constant.StateMergeReady:    "success",
+	constant.StateNotMergeReady: "failure",
+	constant.StateRunning:       "pending",
+	constant.StateError:         "error",
+}
+
+var statusContext = "cdkbot"
+
+// SetStatus set status of latest commit
+func (c *Client) SetStatus(
+	ctx context.Context,
+	state constant.State,
+	description string,
+) error {
+	pr, err := c.GetPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	// maximum is 255 characters
+	if len(description) > 255 {
+		description = description[:255]
+	}
+
+	return c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("statuses/%s", pr.HeadCommitHash)), map[string]string{
+		"state":       stateMap[state],
+		"context":     statusContext,
+		"description": description,
+	}, nil)
+}
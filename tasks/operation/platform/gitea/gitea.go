@@ -0,0 +1,248 @@
+package gitea
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/command"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/gitea/client"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"golang.org/x/xerrors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type repository struct {
+	FullName string `json:"full_name"`
+	Name     string `json:"name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	CloneURL string `json:"clone_url"`
+}
+
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository repository `json:"repository"`
+}
+
+type issueCommentEvent struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	Comment struct {
+		Body string `json:"body"`
+	} `json:"comment"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Repository repository `json:"repository"`
+	IsPull     bool       `json:"is_pull"`
+}
+
+type pushEvent struct {
+	Ref        string     `json:"ref"`
+	Repository repository `json:"repository"`
+}
+
+// Handler handles Gitea webhook
+func Handler(
+	ctx context.Context,
+	req events.APIGatewayProxyRequest,
+	logger logger.Loggerer,
+) error {
+	hook, err := parse(req)
+	if err != nil {
+		return err
+	}
+
+	switch ev := hook.(type) {
+	case *pullRequestEvent:
+		cloneURL, err := constructCloneURL(ev.Repository.CloneURL)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(
+			client.New(ctx, ev.Repository.Owner.Login, ev.Repository.Name, ev.Number),
+			cloneURL,
+			logger,
+		)
+		switch ev.Action {
+		case "opened":
+			if err := runner.Diff(ctx, nil); err != nil {
+				return err
+			}
+			if err := runner.DeployPreview(ctx); err != nil {
+				return err
+			}
+		case "closed":
+			// closed whether merged or not
+			if err := runner.DestroyPreview(ctx); err != nil {
+				return err
+			}
+		}
+	case *pushEvent:
+		client, err := client.NewWithHeadBranch(
+			ctx,
+			ev.Repository.Owner.Login,
+			ev.Repository.Name,
+			strings.TrimPrefix(ev.Ref, "refs/heads/"),
+		)
+		if err != nil {
+			// When push to branch where PR is not created, nothing is to do
+			return nil
+		}
+		cloneURL, err := constructCloneURL(ev.Repository.CloneURL)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(client, cloneURL, logger)
+		if err := runner.Diff(ctx, nil); err != nil {
+			return err
+		}
+		// redeploy the preview with the pushed commit
+		if err := runner.DeployPreview(ctx); err != nil {
+			return err
+		}
+	case *issueCommentEvent:
+		// Only comments on PR are handled
+		if !ev.IsPull {
+			return nil
+		}
+		cloneURL, err := constructCloneURL(ev.Repository.CloneURL)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(
+			client.New(ctx, ev.Repository.Owner.Login, ev.Repository.Name, ev.Issue.Number),
+			cloneURL,
+			logger,
+		)
+		switch ev.Action {
+		case "created":
+			if err := runner.Run(ctx, ev.Comment.Body, ev.Sender.Login); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MessageGroupID returns the group where webhooks are processed one by one,
+// which is keyed by the repository and the base branch of the PR.
+// Empty is returned if nothing is to do for the webhook.
+func MessageGroupID(ctx context.Context, req events.APIGatewayProxyRequest) (string, error) {
+	hook, err := parse(req)
+	if err != nil {
+		return "", err
+	}
+
+	switch ev := hook.(type) {
+	case *pullRequestEvent:
+		if ev.Action != "opened" && ev.Action != "closed" {
+			return "", nil
+		}
+		return queue.GroupID(ev.Repository.FullName, ev.PullRequest.Base.Ref), nil
+	case *pushEvent:
+		client, err := client.NewWithHeadBranch(
+			ctx,
+			ev.Repository.Owner.Login,
+			ev.Repository.Name,
+			strings.TrimPrefix(ev.Ref, "refs/heads/"),
+		)
+		if err != nil {
+			// When push to branch where PR is not created, nothing is to do
+			return "", nil
+		}
+		pr, err := client.GetPullRequest(ctx)
+		if err != nil {
+			return "", err
+		}
+		return queue.GroupID(ev.Repository.FullName, pr.BaseBranch), nil
+	case *issueCommentEvent:
+		if !ev.IsPull || ev.Action != "created" {
+			return "", nil
+		}
+		// The payload doesn't have the base branch
+		pr, err := client.New(ctx, ev.Repository.Owner.Login, ev.Repository.Name, ev.Issue.Number).GetPullRequest(ctx)
+		if err != nil {
+			return "", err
+		}
+		return queue.GroupID(ev.Repository.FullName, pr.BaseBranch), nil
+	}
+	return "", nil
+}
+
+// parse validates the signature and parses the webhook
+func parse(req events.APIGatewayProxyRequest) (interface{}, error) {
+	if err := validateSignature(
+		req.Headers["X-Gitea-Signature"],
+		[]byte(req.Body),
+		[]byte(os.Getenv("GITEA_WEBHOOK_SECRET")),
+	); err != nil {
+		return nil, xerrors.Errorf("Signature is invalid: %w", err)
+	}
+	hook, err := parseWebHook(req.Headers["X-Gitea-Event"], []byte(req.Body))
+	if err != nil {
+		return nil, xerrors.Errorf("parse hook error: %w", err)
+	}
+	return hook, nil
+}
+
+// validateSignature validates hex encoded HMAC SHA256 signature of the payload
+func validateSignature(signature string, payload, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("GITEA_WEBHOOK_SECRET is not set")
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), actual) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func parseWebHook(eventType string, payload []byte) (interface{}, error) {
+	var event interface{}
+	switch eventType {
+	case "pull_request":
+		event = &pullRequestEvent{}
+	case "issue_comment":
+		event = &issueCommentEvent{}
+	case "push":
+		event = &pushEvent{}
+	default:
+		return nil, fmt.Errorf("unknown X-Gitea-Event in message: %v", eventType)
+	}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func constructCloneURL(cloneURL string) (string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(os.Getenv("GITEA_USER_NAME"), os.Getenv("GITEA_ACCESS_TOKEN"))
+	return u.String(), nil
+}
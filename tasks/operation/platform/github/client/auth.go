@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/google/go-github/v26/github"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Installation tokens are valid for an hour.
+// They are refreshed this long before they expire so that long running commands don't fail.
+const tokenEarlyExpiry = 10 * time.Minute
+
+var (
+	tokenSourcesMu sync.Mutex
+	// cache of installation token sources by installation ID
+	tokenSources = map[int64]oauth2.TokenSource{}
+
+	appUserNameMu sync.Mutex
+	// cache of appUserName
+	appUserNameCache string
+)
+
+// isApp returns whether cdkbot runs as a GitHub App
+func isApp() bool {
+	return os.Getenv("GITHUB_APP_ID") != ""
+}
+
+// NewTokenSource returns the source of the token to call API and clone the repository.
+// When GITHUB_APP_ID is set, the installation token of the GitHub App is returned and cached.
+// If not, GITHUB_ACCESS_TOKEN is used.
+func NewTokenSource(installationID int64) (oauth2.TokenSource, error) {
+	if !isApp() {
+		return oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: os.Getenv("GITHUB_ACCESS_TOKEN")},
+		), nil
+	}
+	if installationID == 0 {
+		return nil, errors.New("installation ID is not found in the payload")
+	}
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	if ts, ok := tokenSources[installationID]; ok {
+		return ts, nil
+	}
+	appClient, err := newAppClient()
+	if err != nil {
+		return nil, err
+	}
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, &installationTokenSource{
+		client:         appClient,
+		installationID: installationID,
+	}, tokenEarlyExpiry)
+	tokenSources[installationID] = ts
+	return ts, nil
+}
+
+// newAppClient creates the client authenticated as the GitHub App
+func newAppClient() (*github.Client, error) {
+	appID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_APP_ID is invalid: %w", err)
+	}
+	key, err := parsePrivateKey([]byte(os.Getenv("GITHUB_APP_PRIVATE_KEY")))
+	if err != nil {
+		return nil, err
+	}
+	return newGitHubClient(&http.Client{
+		Transport: &appTransport{
+			appID: appID,
+			key:   key,
+			base:  http.DefaultTransport,
+		},
+	})
+}
+
+// appUserName returns the login name of the bot user of the GitHub App such as "cdkbot[bot]"
+func appUserName(ctx context.Context) (string, error) {
+	appUserNameMu.Lock()
+	defer appUserNameMu.Unlock()
+	if appUserNameCache != "" {
+		return appUserNameCache, nil
+	}
+	appClient, err := newAppClient()
+	if err != nil {
+		return "", err
+	}
+	// github.App of go-github v26 doesn't have the slug
+	req, err := appClient.NewRequest(http.MethodGet, "app", nil)
+	if err != nil {
+		return "", err
+	}
+	var app struct {
+		Slug string `json:"slug"`
+	}
+	if _, err := appClient.Do(ctx, req, &app); err != nil {
+		return "", err
+	}
+	appUserNameCache = fmt.Sprintf("%s[bot]", app.Slug)
+	return appUserNameCache, nil
+}
+
+// CloneURL returns the clone URL with credentials.
+// When GITHUB_BASE_URL is set, the scheme and host are replaced with its ones
+// so that the repository is cloned from the same GitHub Enterprise Server as API.
+func CloneURL(ts oauth2.TokenSource, cloneURL string) (string, error) {
+	token, err := ts.Token()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	if baseURL := os.Getenv("GITHUB_BASE_URL"); baseURL != "" {
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return "", fmt.Errorf("GITHUB_BASE_URL is invalid: %w", err)
+		}
+		u.Scheme = base.Scheme
+		u.Host = base.Host
+	}
+	if isApp() {
+		u.User = url.UserPassword("x-access-token", token.AccessToken)
+	} else {
+		u.User = url.UserPassword(os.Getenv("GITHUB_USER_NAME"), token.AccessToken)
+	}
+	return u.String(), nil
+}
+
+// installationTokenSource gets a new installation token every time
+type installationTokenSource struct {
+	client         *github.Client
+	installationID int64
+}
+
+// Token gets installation token
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	// It is not bound to a request because token source is cached over requests
+	token, _, err := s.client.Apps.CreateInstallationToken(context.Background(), s.installationID)
+	if err != nil {
+		return nil, fmt.Errorf("create installation token failed: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      token.GetExpiresAt(),
+	}, nil
+}
+
+// appTransport authenticates requests as the GitHub App with JWT
+type appTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+	base  http.RoundTripper
+}
+
+// RoundTrip sets JWT to Authorization header
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := signJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return t.base.RoundTrip(r)
+}
+
+// signJWT signs JWT with RS256 which expires after 10 minutes (maximum) from iat
+func signJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		// to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	signingInput := fmt.Sprintf("%s.%s", encoding.EncodeToString(header), encoding.EncodeToString(claims))
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", signingInput, encoding.EncodeToString(signature)), nil
+}
+
+// parsePrivateKey parses PEM encoded PKCS1 or PKCS8 RSA private key
+func parsePrivateKey(buf []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, errors.New("GITHUB_APP_PRIVATE_KEY is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_APP_PRIVATE_KEY is invalid: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GITHUB_APP_PRIVATE_KEY is not RSA key")
+	}
+	return rsaKey, nil
+}
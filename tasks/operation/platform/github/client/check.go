@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/v26/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var conclusionMap = map[constant.State]string{
+	constant.StateMergeReady:    "success",
+	constant.StateNotMergeReady: "failure",
+	constant.StateError:         "failure",
+}
+
+const (
+	// maximum characters of summary and text
+	maxCheckOutputLength = 65535
+	// maximum annotations per request
+	maxAnnotations = 50
+)
+
+// isChecksMode returns whether results are reported by Check Runs instead of commit statuses.
+// Check Runs can be created only by GitHub Apps.
+func isChecksMode() bool {
+	return os.Getenv("GITHUB_STATUS_MODE") == "checks"
+}
+
+// ReportResult creates a check run for the command when it starts, and completes it with the result.
+// Check runs of all commands have the same name as the commit status so that it is the only one required to merge,
+// and the latest one supersedes the previous results such as a failed diff before /deploy.
+// If the result is reported without starting such as when the job is given up, the check run is created then.
+// Unless GITHUB_STATUS_MODE is checks, it sets a commit status.
+func (c *Client) ReportResult(ctx context.Context, result platform.Result) error {
+	if !isChecksMode() {
+		return c.SetStatus(ctx, result.State, result.Description)
+	}
+	name := statusContext
+	if result.State == constant.StateRunning || c.checkRunID == 0 {
+		pr, _, err := c.client.PullRequests.Get(ctx, c.owner, c.repo, c.number)
+		if err != nil {
+			return err
+		}
+		checkRun, _, err := c.client.Checks.CreateCheckRun(ctx, c.owner, c.repo, github.CreateCheckRunOptions{
+			Name:       name,
+			HeadBranch: pr.GetHead().GetRef(),
+			HeadSHA:    pr.GetHead().GetSHA(),
+			ExternalID: github.String(result.Command),
+			Status:     github.String("in_progress"),
+			StartedAt:  &github.Timestamp{Time: time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		c.checkRunID = checkRun.GetID()
+		if result.State == constant.StateRunning {
+			return nil
+		}
+	}
+	_, _, err := c.client.Checks.UpdateCheckRun(ctx, c.owner, c.repo, c.checkRunID, github.UpdateCheckRunOptions{
+		Name:        name,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusionMap[result.State]),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      checkRunOutput(result),
+		Actions:     checkRunActions(result),
+	})
+	return err
+}
+
+func checkRunOutput(result platform.Result) *github.CheckRunOutput {
+	summary := result.Description
+	if len(result.ChangedStacks) != 0 {
+		lines := []string{summary, "", "Stacks having differences:"}
+		for _, stack := range result.ChangedStacks {
+			lines = append(lines, fmt.Sprintf("- %s", stack))
+		}
+		summary = strings.Join(lines, "\n")
+	}
+	text := fmt.Sprintf("```\n%s\n```", result.Output)
+	if len(text) > maxCheckOutputLength {
+		tail := "\n```\n(truncated)"
+		text = truncate(text, maxCheckOutputLength-len(tail)) + tail
+	}
+	summary = truncate(summary, maxCheckOutputLength)
+	annotations := []*github.CheckRunAnnotation{}
+	for _, stack := range result.ChangedStacks {
+		if len(annotations) == maxAnnotations {
+			break
+		}
+		// Stacks are not bound to files so point at cdk.json
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(path.Join(result.CDKRoot, "cdk.json")),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String("notice"),
+			Title:           github.String(stack),
+			Message:         github.String(fmt.Sprintf("Stack %s has differences", stack)),
+		})
+	}
+	return &github.CheckRunOutput{
+		Title:       github.String(fmt.Sprintf("/%s: %s", result.Command, result.Description)),
+		Summary:     github.String(summary),
+		Text:        github.String(text),
+		Annotations: annotations,
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a multi-byte character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// checkRunActions returns buttons on the check run. Their identifiers are the commands to run.
+// Re-run is offered only for /diff because arguments of the original command such as stacks are not kept,
+// and running /deploy or /rollback without them affects all stacks.
+func checkRunActions(result platform.Result) []*github.CheckRunAction {
+	actions := []*github.CheckRunAction{}
+	if result.Command == "diff" {
+		actions = append(actions, &github.CheckRunAction{
+			Label:       "Re-run",
+			Description: "Run /diff again for all stacks",
+			Identifier:  "/diff",
+		})
+	}
+	if result.State == constant.StateNotMergeReady && len(result.ChangedStacks) != 0 {
+		actions = append(actions, &github.CheckRunAction{
+			Label:       "Deploy",
+			Description: "Run /deploy for all stacks",
+			Identifier:  "/deploy",
+		})
+	}
+	return actions
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v26/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ReportResult(t *testing.T) {
+	tests := []struct {
+		title    string
+		in       []platform.Result
+		expected []string
+	}{
+		{
+			title: "started_and_completed",
+			in: []platform.Result{
+				{Command: "diff", State: constant.StateRunning},
+				{Command: "diff", State: constant.StateMergeReady, Description: "No diffs. Let's merge!"},
+			},
+			expected: []string{"POST in_progress", "PATCH completed success"},
+		},
+		{
+			title: "completed_without_starting",
+			in: []platform.Result{
+				{Command: "deploy", State: constant.StateError, Description: "Failed 3 times and given up"},
+			},
+			expected: []string{"POST in_progress", "PATCH completed failure"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			t.Setenv("GITHUB_STATUS_MODE", "checks")
+			requests := []string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/repos/owner/repo/pulls/1":
+					json.NewEncoder(w).Encode(github.PullRequest{Head: &github.PullRequestBranch{Ref: github.String("feature"), SHA: github.String("headhash")}})
+				case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/check-runs":
+					var opts github.CreateCheckRunOptions
+					assert.Nil(t, json.NewDecoder(r.Body).Decode(&opts))
+					assert.Equal(t, "headhash", opts.HeadSHA)
+					assert.Equal(t, "cdkbot", opts.Name)
+					requests = append(requests, fmt.Sprintf("POST %s", opts.GetStatus()))
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(10)})
+				case r.Method == http.MethodPatch && r.URL.Path == "/repos/owner/repo/check-runs/10":
+					var opts github.UpdateCheckRunOptions
+					assert.Nil(t, json.NewDecoder(r.Body).Decode(&opts))
+					requests = append(requests, fmt.Sprintf("PATCH %s %s", opts.GetStatus(), opts.GetConclusion()))
+					json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(10)})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+			}))
+			defer server.Close()
+			ghClient := github.NewClient(server.Client())
+			ghClient.BaseURL, _ = url.Parse(server.URL + "/")
+
+			client := &Client{client: ghClient, owner: "owner", repo: "repo", number: 1}
+			for _, result := range test.in {
+				assert.Nil(t, client.ReportResult(context.Background(), result))
+			}
+			assert.Equal(t, test.expected, requests)
+		})
+	}
+}
+
+func TestCheckRunOutput(t *testing.T) {
+	tests := []struct {
+		title               string
+		in                  platform.Result
+		expectedSummary     string
+		expectedAnnotations int
+	}{
+		{
+			title: "no differences",
+			in: platform.Result{
+				Command:     "diff",
+				State:       constant.StateMergeReady,
+				Description: "No stacks are updated",
+				Output:      "There were no differences",
+				CDKRoot:     ".",
+			},
+			expectedSummary:     "No stacks are updated",
+			expectedAnnotations: 0,
+		},
+		{
+			title: "has differences",
+			in: platform.Result{
+				Command:       "diff",
+				State:         constant.StateNotMergeReady,
+				Description:   "Diffs still remain",
+				Output:        "Stack Stack1\n...",
+				ChangedStacks: []string{"Stack1", "Stack2"},
+				CDKRoot:       "./cdk",
+			},
+			expectedSummary:     "Diffs still remain\n\nStacks having differences:\n- Stack1\n- Stack2",
+			expectedAnnotations: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			output := checkRunOutput(test.in)
+			assert.Equal(t, "/diff: "+test.in.Description, output.GetTitle())
+			assert.Equal(t, test.expectedSummary, output.GetSummary())
+			assert.Equal(t, "```\n"+test.in.Output+"\n```", output.GetText())
+			assert.Len(t, output.Annotations, test.expectedAnnotations)
+			for _, annotation := range output.Annotations {
+				assert.Equal(t, "cdk/cdk.json", annotation.GetPath())
+			}
+		})
+	}
+}
+
+func TestCheckRunOutput_Truncate(t *testing.T) {
+	stacks := []string{}
+	for i := 0; i < maxAnnotations+1; i++ {
+		stacks = append(stacks, "Stack")
+	}
+	output := checkRunOutput(platform.Result{
+		Command:       "diff",
+		State:         constant.StateNotMergeReady,
+		Output:        strings.Repeat("a", maxCheckOutputLength),
+		ChangedStacks: stacks,
+	})
+	assert.Len(t, output.GetText(), maxCheckOutputLength)
+	assert.True(t, strings.HasSuffix(output.GetText(), "(truncated)"))
+	assert.Len(t, output.Annotations, maxAnnotations)
+
+	// multi-byte characters are not split
+	output = checkRunOutput(platform.Result{
+		Command: "diff",
+		State:   constant.StateNotMergeReady,
+		Output:  strings.Repeat("あ", maxCheckOutputLength),
+	})
+	assert.LessOrEqual(t, len(output.GetText()), maxCheckOutputLength)
+	assert.True(t, utf8.ValidString(output.GetText()))
+	assert.True(t, strings.HasSuffix(output.GetText(), "あ\n```\n(truncated)"))
+}
+
+func TestCheckRunActions(t *testing.T) {
+	tests := []struct {
+		title       string
+		in          platform.Result
+		expectedIDs []string
+	}{
+		{
+			title: "merge ready",
+			in: platform.Result{
+				Command: "diff",
+				State:   constant.StateMergeReady,
+			},
+			expectedIDs: []string{"/diff"},
+		},
+		{
+			title: "has differences",
+			in: platform.Result{
+				Command:       "diff",
+				State:         constant.StateNotMergeReady,
+				ChangedStacks: []string{"Stack1"},
+			},
+			expectedIDs: []string{"/diff", "/deploy"},
+		},
+		{
+			title: "error",
+			in: platform.Result{
+				Command: "rollback",
+				State:   constant.StateError,
+			},
+			expectedIDs: []string{},
+		},
+		{
+			title: "deploy has differences",
+			in: platform.Result{
+				Command:       "deploy",
+				State:         constant.StateNotMergeReady,
+				ChangedStacks: []string{"Stack1"},
+			},
+			expectedIDs: []string{"/deploy"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			ids := []string{}
+			for _, action := range checkRunActions(test.in) {
+				// limits of GitHub API
+				assert.LessOrEqual(t, len(action.Label), 20)
+				assert.LessOrEqual(t, len(action.Description), 40)
+				ids = append(ids, action.Identifier)
+			}
+			assert.Equal(t, test.expectedIDs, ids)
+		})
+	}
+}
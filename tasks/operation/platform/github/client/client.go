@@ -3,7 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/google/go-github/v26/github"
 	"golang.org/x/oauth2"
@@ -15,39 +18,42 @@ type Client struct {
 	owner  string
 	repo   string
 	number int
+	// ID of the check run created by ReportResult
+	checkRunID int64
 }
 
-// New GitHub client
+// New GitHub client. ts is created by NewTokenSource
 func New(
 	ctx context.Context,
+	ts oauth2.TokenSource,
 	owner string,
 	repo string,
 	number int,
-) *Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_ACCESS_TOKEN")},
-	)
-	tc := oauth2.NewClient(ctx, ts)
+) (*Client, error) {
+	client, err := newGitHubClient(oauth2.NewClient(ctx, ts))
+	if err != nil {
+		return nil, err
+	}
 	return &Client{
-		client: github.NewClient(tc),
+		client: client,
 		owner:  owner,
 		repo:   repo,
 		number: number,
-	}
+	}, nil
 }
 
 // NewWithHeadBranch create GitHub client with head branch
 func NewWithHeadBranch(
 	ctx context.Context,
+	ts oauth2.TokenSource,
 	owner string,
 	repo string,
 	headBranch string,
 ) (*Client, error) {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_ACCESS_TOKEN")},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+	client, err := newGitHubClient(oauth2.NewClient(ctx, ts))
+	if err != nil {
+		return nil, err
+	}
 	prs, _, err := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
 		Head: headBranch,
 	})
@@ -57,7 +63,41 @@ func NewWithHeadBranch(
 	if len(prs) == 0 {
 		return nil, fmt.Errorf("PR is not found with head branch %s", headBranch)
 	}
-	return New(ctx, owner, repo, prs[0].GetNumber()), nil
+	return New(ctx, ts, owner, repo, prs[0].GetNumber())
+}
+
+// newGitHubClient creates client of github.com,
+// or GitHub Enterprise Server if GITHUB_BASE_URL (e.g. https://github.example.com/api/v3/) is set.
+// GITHUB_UPLOAD_URL defaults to /api/uploads/ of the GitHub Enterprise Server.
+func newGitHubClient(httpClient *http.Client) (*github.Client, error) {
+	baseURL := os.Getenv("GITHUB_BASE_URL")
+	if baseURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+	uploadURL := os.Getenv("GITHUB_UPLOAD_URL")
+	if uploadURL == "" {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("GITHUB_BASE_URL is invalid: %w", err)
+		}
+		// such as https://github.example.com/api/v3/ to https://github.example.com/api/uploads/
+		u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3") + "/api/uploads/"
+		uploadURL = u.String()
+	}
+	client, err := github.NewEnterpriseClient(baseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_BASE_URL or GITHUB_UPLOAD_URL is invalid: %w", err)
+	}
+	return client, nil
+}
+
+// GetUserName returns the login name of cdkbot itself.
+// It is GITHUB_USER_NAME for the access token, or the bot user for the GitHub App.
+func (c *Client) GetUserName(ctx context.Context) (string, error) {
+	if !isApp() {
+		return os.Getenv("GITHUB_USER_NAME"), nil
+	}
+	return appUserName(ctx)
 }
 
 const maxPage = 50
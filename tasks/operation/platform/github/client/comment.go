@@ -49,6 +49,7 @@ func (c *Client) ListComments(
 		ret = append(ret, platform.Comment{
 			ID:   comment.GetID(),
 			Body: comment.GetBody(),
+			User: comment.GetUser().GetLogin(),
 		})
 	}
 	return ret, nil
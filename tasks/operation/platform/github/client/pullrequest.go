@@ -28,6 +28,7 @@ func (c *Client) GetPullRequest(ctx context.Context) (*platform.PullRequest, err
 		BaseCommitHash: pr.GetBase().GetSHA(),
 		HeadCommitHash: pr.GetHead().GetSHA(),
 		Labels:         labels,
+		Author:         pr.GetUser().GetLogin(),
 	}, nil
 }
 
@@ -69,6 +70,7 @@ func (c *Client) GetOpenPullRequests(ctx context.Context) ([]platform.PullReques
 			BaseCommitHash: pr.GetBase().GetSHA(),
 			HeadCommitHash: pr.GetHead().GetSHA(),
 			Labels:         labels,
+			Author:         pr.GetUser().GetLogin(),
 		})
 	}
 	return ret, nil
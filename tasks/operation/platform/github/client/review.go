@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-github/v26/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+)
+
+// ListReviews gets approvals and change requests order by submitted asc
+func (c *Client) ListReviews(ctx context.Context) ([]platform.Review, error) {
+	page := 1
+	reviews := []*github.PullRequestReview{}
+	for true {
+		paging, _, err := c.client.PullRequests.ListReviews(ctx, c.owner, c.repo, c.number, &github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		reviews = append(reviews, paging...)
+		page++
+		if page > maxPage {
+			return nil, errors.New("Too many reviews")
+		}
+	}
+	return toReviews(reviews), nil
+}
+
+// toReviews converts reviews. COMMENTED, DISMISSED and PENDING ones are skipped.
+func toReviews(reviews []*github.PullRequestReview) []platform.Review {
+	ret := []platform.Review{}
+	for _, review := range reviews {
+		state := review.GetState()
+		if state != "APPROVED" && state != "CHANGES_REQUESTED" {
+			continue
+		}
+		ret = append(ret, platform.Review{
+			User:       review.GetUser().GetLogin(),
+			Approved:   state == "APPROVED",
+			CommitHash: review.GetCommitID(),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v26/github"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToReviews(t *testing.T) {
+	review := func(user, state, commitID string) *github.PullRequestReview {
+		return &github.PullRequestReview{
+			User:     &github.User{Login: github.String(user)},
+			State:    github.String(state),
+			CommitID: github.String(commitID),
+		}
+	}
+	assert.Equal(t, []platform.Review{
+		{User: "alice", Approved: true, CommitHash: "hash1"},
+		{User: "bob", Approved: false, CommitHash: "hash2"},
+	}, toReviews([]*github.PullRequestReview{
+		review("alice", "APPROVED", "hash1"),
+		review("carol", "COMMENTED", "hash1"),
+		review("bob", "CHANGES_REQUESTED", "hash2"),
+		review("dave", "DISMISSED", "hash2"),
+	}))
+}
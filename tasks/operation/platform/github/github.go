@@ -6,6 +6,7 @@ import (
 	"github.com/sambaiz/cdkbot/tasks/operation/command"
 	"github.com/sambaiz/cdkbot/tasks/operation/logger"
 	"github.com/sambaiz/cdkbot/tasks/operation/platform/github/client"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
 	"golang.org/x/xerrors"
 	"os"
 	"strings"
@@ -20,77 +21,221 @@ func Handler(
 	req events.APIGatewayProxyRequest,
 	logger logger.Loggerer,
 ) error {
-	if err := goGitHub.ValidateSignature(
-		req.Headers["X-Hub-Signature"],
-		[]byte(req.Body),
-		[]byte(os.Getenv("GITHUB_WEBHOOK_SECRET")),
-	); err != nil {
-		return xerrors.Errorf("Signature is invalid: %w", err)
-	}
-	hook, err := goGitHub.ParseWebHook(req.Headers["X-GitHub-Event"], []byte(req.Body))
+	hook, err := parse(req)
 	if err != nil {
-		return xerrors.Errorf("parse hook error: %w", err)
+		return err
 	}
 
 	switch ev := hook.(type) {
 	case *goGitHub.PullRequestEvent:
-		runner := command.NewRunner(
-			client.New(
-				ctx,
-				ev.GetRepo().GetOwner().GetLogin(),
-				ev.GetRepo().GetName(),
-				ev.GetPullRequest().GetNumber(),
-			),
-			fmt.Sprintf("https://%s:%s@%s",
-				os.Getenv("GITHUB_USER_NAME"),
-				os.Getenv("GITHUB_ACCESS_TOKEN"),
-				strings.Replace(ev.GetRepo().GetCloneURL(), "https://", "", 1)),
-			logger,
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return err
+		}
+		cloneURL, err := client.CloneURL(ts, ev.GetRepo().GetCloneURL())
+		if err != nil {
+			return err
+		}
+		ghClient, err := client.New(
+			ctx,
+			ts,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			ev.GetPullRequest().GetNumber(),
 		)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(ghClient, cloneURL, logger)
 		switch ev.GetAction() {
 		case "opened":
-			err = runner.Diff(ctx)
+			if err := runner.Diff(ctx, nil); err != nil {
+				return err
+			}
+			return runner.DeployPreview(ctx)
+		case "closed":
+			// closed whether merged or not
+			return runner.DestroyPreview(ctx)
 		}
 	case *goGitHub.PushEvent:
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return err
+		}
+		cloneURL, err := client.CloneURL(ts, ev.GetRepo().GetCloneURL())
+		if err != nil {
+			return err
+		}
 		client, err := client.NewWithHeadBranch(
 			ctx,
+			ts,
 			ev.GetRepo().GetOwner().GetLogin(),
 			ev.GetRepo().GetName(),
-			strings.TrimLeft(ev.GetRef(), "refs/heads/"),
+			strings.TrimPrefix(ev.GetRef(), "refs/heads/"),
 		)
 		if err != nil {
 			// When push to branch where PR is not created, nothing is to do
 			return nil
 		}
-		err = command.NewRunner(
-			client,
-			fmt.Sprintf("https://%s:%s@%s",
-				os.Getenv("GITHUB_USER_NAME"),
-				os.Getenv("GITHUB_ACCESS_TOKEN"),
-				strings.Replace(ev.GetRepo().GetCloneURL(), "https://", "", 1)),
-			logger,
-		).Diff(ctx)
+		runner := command.NewRunner(client, cloneURL, logger)
+		if err := runner.Diff(ctx, nil); err != nil {
+			return err
+		}
+		// redeploy the preview with the pushed commit
+		return runner.DeployPreview(ctx)
 	case *goGitHub.IssueCommentEvent:
-		runner := command.NewRunner(
-			client.New(
-				ctx,
-				ev.GetRepo().GetOwner().GetLogin(),
-				ev.GetRepo().GetName(),
-				ev.GetIssue().GetNumber(),
-			),
-			fmt.Sprintf("https://%s:%s@%s",
-				os.Getenv("GITHUB_USER_NAME"),
-				os.Getenv("GITHUB_ACCESS_TOKEN"),
-				strings.Replace(ev.GetRepo().GetCloneURL(), "https://", "", 1)),
-			logger,
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return err
+		}
+		cloneURL, err := client.CloneURL(ts, ev.GetRepo().GetCloneURL())
+		if err != nil {
+			return err
+		}
+		ghClient, err := client.New(
+			ctx,
+			ts,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			ev.GetIssue().GetNumber(),
 		)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(ghClient, cloneURL, logger)
 		switch ev.GetAction() {
 		case "created":
-			err = runner.Run(ctx, ev.GetComment().GetBody(), ev.GetSender().GetLogin())
+			return runner.Run(ctx, ev.GetComment().GetBody(), ev.GetSender().GetLogin())
+		}
+	case *goGitHub.CheckRunEvent:
+		// Check runs are created by cdkbot when GITHUB_STATUS_MODE is checks
+		if !isCheckRunRequested(ev) {
+			return nil
+		}
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return err
+		}
+		cloneURL, err := client.CloneURL(ts, ev.GetRepo().GetCloneURL())
+		if err != nil {
+			return err
+		}
+		ghClient, err := client.New(
+			ctx,
+			ts,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			ev.GetCheckRun().PullRequests[0].GetNumber(),
+		)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(ghClient, cloneURL, logger)
+		switch ev.GetAction() {
+		case "requested_action":
+			// identifier of the action is the command
+			return runner.Run(ctx, ev.GetRequestedAction().Identifier, ev.GetSender().GetLogin())
+		case "rerequested":
+			// the check run doesn't keep arguments of the command such as stacks
+			if command := ev.GetCheckRun().GetExternalID(); command != "diff" {
+				return ghClient.CreateComment(ctx, fmt.Sprintf("/%s can't be re-run from the check run. Comment the command again.", command))
+			}
+			return runner.Diff(ctx, nil)
 		}
 	}
+	return nil
+}
+
+// MessageGroupID returns the group where webhooks are processed one by one,
+// which is keyed by the repository and the base branch of the PR.
+// Empty is returned if nothing is to do for the webhook.
+func MessageGroupID(ctx context.Context, req events.APIGatewayProxyRequest) (string, error) {
+	hook, err := parse(req)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+
+	switch ev := hook.(type) {
+	case *goGitHub.PullRequestEvent:
+		if ev.GetAction() != "opened" && ev.GetAction() != "closed" {
+			return "", nil
+		}
+		return queue.GroupID(ev.GetRepo().GetFullName(), ev.GetPullRequest().GetBase().GetRef()), nil
+	case *goGitHub.PushEvent:
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return "", err
+		}
+		client, err := client.NewWithHeadBranch(
+			ctx,
+			ts,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			strings.TrimPrefix(ev.GetRef(), "refs/heads/"),
+		)
+		if err != nil {
+			// When push to branch where PR is not created, nothing is to do
+			return "", nil
+		}
+		pr, err := client.GetPullRequest(ctx)
+		if err != nil {
+			return "", err
+		}
+		return queue.GroupID(ev.GetRepo().GetFullName(), pr.BaseBranch), nil
+	case *goGitHub.IssueCommentEvent:
+		if ev.GetAction() != "created" || !ev.GetIssue().IsPullRequest() {
+			return "", nil
+		}
+		ts, err := client.NewTokenSource(ev.GetInstallation().GetID())
+		if err != nil {
+			return "", err
+		}
+		// The payload doesn't have the base branch
+		ghClient, err := client.New(
+			ctx,
+			ts,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			ev.GetIssue().GetNumber(),
+		)
+		if err != nil {
+			return "", err
+		}
+		pr, err := ghClient.GetPullRequest(ctx)
+		if err != nil {
+			return "", err
+		}
+		return queue.GroupID(ev.GetRepo().GetFullName(), pr.BaseBranch), nil
+	case *goGitHub.CheckRunEvent:
+		if !isCheckRunRequested(ev) {
+			return "", nil
+		}
+		return queue.GroupID(ev.GetRepo().GetFullName(), ev.GetCheckRun().PullRequests[0].GetBase().GetRef()), nil
+	}
+	return "", nil
+}
+
+// isCheckRunRequested returns whether a user requested something on the check run of the PR.
+// Events of creating and completing check runs by cdkbot itself are ignored not to queue jobs doing nothing.
+func isCheckRunRequested(ev *goGitHub.CheckRunEvent) bool {
+	if len(ev.GetCheckRun().PullRequests) == 0 {
+		return false
+	}
+	return ev.GetAction() == "requested_action" || ev.GetAction() == "rerequested"
+}
+
+// parse validates the signature and parses the webhook
+func parse(req events.APIGatewayProxyRequest) (interface{}, error) {
+	if err := goGitHub.ValidateSignature(
+		req.Headers["X-Hub-Signature"],
+		[]byte(req.Body),
+		[]byte(os.Getenv("GITHUB_WEBHOOK_SECRET")),
+	); err != nil {
+		return nil, xerrors.Errorf("Signature is invalid: %w", err)
+	}
+	hook, err := goGitHub.ParseWebHook(req.Headers["X-GitHub-Event"], []byte(req.Body))
+	if err != nil {
+		return nil, xerrors.Errorf("parse hook error: %w", err)
+	}
+	return hook, nil
 }
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Client is an implementation of platform.Client
+type Client struct {
+	client    *http.Client
+	baseURL   string
+	token     string
+	projectID int
+	number    int
+	// cache of GetUserName
+	userName string
+}
+
+const defaultBaseURL = "https://gitlab.com/api/v4"
+
+// New GitLab client. number is the IID of the merge request
+func New(
+	ctx context.Context,
+	projectID int,
+	number int,
+) *Client {
+	baseURL := os.Getenv("GITLAB_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &Client{
+		client:    http.DefaultClient,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		token:     os.Getenv("GITLAB_ACCESS_TOKEN"),
+		projectID: projectID,
+		number:    number,
+	}
+}
+
+// NewWithHeadBranch create GitLab client with head (source) branch
+func NewWithHeadBranch(
+	ctx context.Context,
+	projectID int,
+	headBranch string,
+) (*Client, error) {
+	client := New(ctx, projectID, 0)
+	var mrs []mergeRequest
+	if err := client.do(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("%s?state=opened&source_branch=%s", client.projectPath("merge_requests"), url.QueryEscape(headBranch)),
+		nil,
+		&mrs,
+	); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, fmt.Errorf("MR is not found with head branch %s", headBranch)
+	}
+	client.number = mrs[0].IID
+	return client, nil
+}
+
+const (
+	maxPage = 50
+	perPage = 100
+)
+
+// GetUserName returns the name of the user of the token, who is cdkbot itself
+func (c *Client) GetUserName(ctx context.Context) (string, error) {
+	if c.userName != "" {
+		return c.userName, nil
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return "", err
+	}
+	c.userName = user.Username
+	return user.Username, nil
+}
+
+func (c *Client) projectPath(path string) string {
+	return fmt.Sprintf("/projects/%d/%s", c.projectID, path)
+}
+
+func (c *Client) mergeRequestPath(path string) string {
+	if path == "" {
+		return c.projectPath(fmt.Sprintf("merge_requests/%d", c.number))
+	}
+	return c.projectPath(fmt.Sprintf("merge_requests/%d/%s", c.number, path))
+}
+
+// APIError is returned when GitLab API responds with non 2xx status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitLab API error: %d %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(buf)}
+	}
+	if out == nil || len(buf) == 0 {
+		return nil
+	}
+	return json.Unmarshal(buf, out)
+}
@@ -0,0 +1,338 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitLab is a stand-in of GitLab API having one project
+type fakeGitLab struct {
+	mu            sync.Mutex
+	projectID     int
+	mergeRequests map[int]*mergeRequest
+	notes         []note
+	approvedBy    []string
+	labels        map[string]label
+	statuses      map[string][]string
+	merged        map[int]string
+	snippets      []map[string]string
+}
+
+func newFakeGitLab(projectID int) *fakeGitLab {
+	return &fakeGitLab{
+		projectID:     projectID,
+		mergeRequests: map[int]*mergeRequest{},
+		labels:        map[string]label{},
+		statuses:      map[string][]string{},
+		merged:        map[int]string{},
+	}
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/api/v4/user" {
+		w.Write([]byte(`{"username": "cdkbot"}`))
+		return
+	}
+	prefix := fmt.Sprintf("/api/v4/projects/%d/", f.projectID)
+	if !strings.HasPrefix(r.URL.EscapedPath(), prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), prefix), "/")
+	var body map[string]string
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	switch {
+	case parts[0] == "merge_requests" && len(parts) == 1 && r.Method == http.MethodGet:
+		ret := []mergeRequest{}
+		if page <= 1 {
+			for _, mr := range f.mergeRequests {
+				if branch := r.URL.Query().Get("source_branch"); branch != "" && branch != "feature" {
+					continue
+				}
+				ret = append(ret, *mr)
+			}
+		}
+		json.NewEncoder(w).Encode(ret)
+	case parts[0] == "merge_requests" && len(parts) >= 2:
+		iid, _ := strconv.Atoi(parts[1])
+		mr, ok := f.mergeRequests[iid]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(mr)
+		case len(parts) == 2 && r.Method == http.MethodPut:
+			if name, ok := body["add_labels"]; ok {
+				mr.Labels = append(mr.Labels, name)
+			}
+			if name, ok := body["remove_labels"]; ok {
+				labels := []string{}
+				for _, lb := range mr.Labels {
+					if lb != name {
+						labels = append(labels, lb)
+					}
+				}
+				mr.Labels = labels
+			}
+			json.NewEncoder(w).Encode(mr)
+		case parts[2] == "merge" && r.Method == http.MethodPut:
+			f.merged[iid] = body["merge_commit_message"]
+			json.NewEncoder(w).Encode(mr)
+		case parts[2] == "notes" && len(parts) == 3 && r.Method == http.MethodGet:
+			if page > 1 {
+				w.Write([]byte("[]"))
+				return
+			}
+			json.NewEncoder(w).Encode(f.notes)
+		case parts[2] == "approvals" && r.Method == http.MethodGet:
+			approvedBy := []map[string]map[string]string{}
+			for _, user := range f.approvedBy {
+				approvedBy = append(approvedBy, map[string]map[string]string{"user": {"username": user}})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"approved_by": approvedBy})
+		case parts[2] == "notes" && len(parts) == 3 && r.Method == http.MethodPost:
+			n := note{ID: int64(len(f.notes) + 1), Body: body["body"]}
+			n.Author.Username = "cdkbot"
+			f.notes = append(f.notes, n)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(n)
+		case parts[2] == "notes" && len(parts) == 4 && r.Method == http.MethodDelete:
+			id, _ := strconv.ParseInt(parts[3], 10, 64)
+			notes := []note{}
+			for _, n := range f.notes {
+				if n.ID != id {
+					notes = append(notes, n)
+				}
+			}
+			f.notes = notes
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case parts[0] == "labels" && len(parts) == 1 && r.Method == http.MethodPost:
+		f.labels[body["name"]] = label{Name: body["name"], Color: body["color"], Description: body["description"]}
+		w.WriteHeader(http.StatusCreated)
+	case parts[0] == "labels" && len(parts) == 2:
+		name, _ := url.PathUnescape(parts[1])
+		lb, ok := f.labels[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPut {
+			lb.Color = body["color"]
+			lb.Description = body["description"]
+			f.labels[name] = lb
+		}
+		json.NewEncoder(w).Encode(lb)
+	case parts[0] == "snippets" && len(parts) == 1 && r.Method == http.MethodPost:
+		f.snippets = append(f.snippets, body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(snippet{WebURL: fmt.Sprintf("https://gitlab.example.com/snippets/%d", len(f.snippets))})
+	case parts[0] == "statuses" && len(parts) == 2 && r.Method == http.MethodPost:
+		states := f.statuses[parts[1]]
+		if len(states) != 0 && states[len(states)-1] == body["state"] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"Cannot transition status via :run from :running"}`))
+			return
+		}
+		f.statuses[parts[1]] = append(states, body["state"])
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func setupClient(t *testing.T) (*Client, *fakeGitLab) {
+	fake := newFakeGitLab(1)
+	fake.mergeRequests[2] = &mergeRequest{
+		IID:          2,
+		TargetBranch: "develop",
+		SHA:          "headhash",
+	}
+	fake.mergeRequests[2].DiffRefs.BaseSHA = "basehash"
+	fake.mergeRequests[2].Author.Username = "sambaiz"
+	fake.mergeRequests[3] = &mergeRequest{
+		IID:          3,
+		TargetBranch: "develop",
+		SHA:          "headhash3",
+		Labels:       []string{constant.LabelDeployed.Name, "other"},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return &Client{
+		client:    server.Client(),
+		baseURL:   server.URL + "/api/v4",
+		token:     "token",
+		projectID: 1,
+		number:    2,
+	}, fake
+}
+
+func TestNewWithHeadBranch(t *testing.T) {
+	ctx := context.Background()
+	_, fake := setupClient(t)
+	delete(fake.mergeRequests, 3)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv("GITLAB_BASE_URL", server.URL+"/api/v4/")
+	t.Setenv("GITLAB_ACCESS_TOKEN", "token")
+
+	client, err := NewWithHeadBranch(ctx, 1, "feature")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.number)
+
+	_, err = NewWithHeadBranch(ctx, 1, "notfound")
+	assert.NotNil(t, err)
+}
+
+func TestClient_Comment(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	fake.notes = []note{{ID: 1, Body: "added ~cdkbot:running label", System: true}}
+	first := note{ID: 2, Body: "first"}
+	first.Author.Username = "sambaiz"
+	fake.notes = append(fake.notes, first)
+
+	assert.Nil(t, client.CreateComment(ctx, "second"))
+	comments, err := client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 2, Body: "first", User: "sambaiz"}, {ID: 3, Body: "second", User: "cdkbot"}}, comments)
+
+	assert.Nil(t, client.DeleteComment(ctx, 2))
+	comments, err = client.ListComments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Comment{{ID: 3, Body: "second", User: "cdkbot"}}, comments)
+}
+
+func TestClient_Label(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	fake.labels[constant.LabelDeployed.Name] = label{Name: constant.LabelDeployed.Name, Color: "#000000"}
+
+	assert.Nil(t, client.AddLabel(ctx, constant.LabelRunning))
+	assert.Nil(t, client.AddLabelToOtherPR(ctx, constant.LabelDeployed, 3))
+	assert.Equal(t, []string{constant.LabelRunning.Name}, fake.mergeRequests[2].Labels)
+	assert.Equal(t, label{
+		Name:        constant.LabelRunning.Name,
+		Color:       "#" + constant.LabelRunning.Color,
+		Description: constant.LabelRunning.Description,
+	}, fake.labels[constant.LabelRunning.Name])
+	// existing label is updated
+	assert.Equal(t, "#"+constant.LabelDeployed.Color, fake.labels[constant.LabelDeployed.Name].Color)
+
+	assert.Nil(t, client.RemoveLabel(ctx, constant.LabelRunning))
+	assert.Equal(t, []string{}, fake.mergeRequests[2].Labels)
+}
+
+func TestClient_PullRequest(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	pr, err := client.GetPullRequest(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &platform.PullRequest{
+		Number:         2,
+		BaseBranch:     "develop",
+		BaseCommitHash: "basehash",
+		HeadCommitHash: "headhash",
+		Labels:         map[string]constant.Label{},
+		Author:         "sambaiz",
+	}, pr)
+
+	prs, err := client.GetOpenPullRequests(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, prs, 2)
+	for _, pr := range prs {
+		if pr.Number == 3 {
+			assert.Equal(t, map[string]constant.Label{constant.LabelDeployed.Name: constant.LabelDeployed}, pr.Labels)
+		}
+	}
+
+	assert.Nil(t, client.MergePullRequest(ctx, "automatically merged by cdkbot"))
+	assert.Equal(t, "automatically merged by cdkbot", fake.merged[2])
+}
+
+func TestClient_GetUserName(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+
+	name, err := client.GetUserName(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "cdkbot", name)
+}
+
+func TestClient_ListReviews(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	fake.approvedBy = []string{"alice", "carol"}
+
+	reviews, err := client.ListReviews(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []platform.Review{
+		{User: "alice", Approved: true, CommitHash: "headhash"},
+		{User: "carol", Approved: true, CommitHash: "headhash"},
+	}, reviews)
+}
+
+func TestClient_SetStatus(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+
+	assert.Nil(t, client.SetStatus(ctx, constant.StateRunning, ""))
+	// same state is ignored
+	assert.Nil(t, client.SetStatus(ctx, constant.StateRunning, ""))
+	assert.Nil(t, client.SetStatus(ctx, constant.StateNotMergeReady, strings.Repeat("a", 300)))
+	assert.Equal(t, []string{"running", "failed"}, fake.statuses["headhash"])
+}
+
+func TestClient_APIError(t *testing.T) {
+	ctx := context.Background()
+	client, _ := setupClient(t)
+	client.token = "invalid"
+
+	_, err := client.GetPullRequest(ctx)
+	apiErr, ok := err.(*APIError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestClient_UploadFile(t *testing.T) {
+	ctx := context.Background()
+	client, fake := setupClient(t)
+	var uploader platform.FileUploader = client
+	url, err := uploader.UploadFile(ctx, "Stack1.template.json", "{}")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://gitlab.example.com/snippets/1", url)
+	assert.Equal(t, []map[string]string{
+		{
+			"title":      "Stack1.template.json of !2 by cdkbot",
+			"file_name":  "Stack1.template.json",
+			"content":    "{}",
+			"visibility": "private",
+		},
+	}, fake.snippets)
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type note struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// CreateComment creates a note
+func (c *Client) CreateComment(
+	ctx context.Context,
+	body string,
+) error {
+	return c.do(ctx, http.MethodPost, c.mergeRequestPath("notes"), map[string]string{
+		"body": body,
+	}, nil)
+}
+
+// ListComments gets notes order by posted asc
+func (c *Client) ListComments(
+	ctx context.Context,
+) ([]platform.Comment, error) {
+	notes, err := c.listNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := []platform.Comment{}
+	for _, note := range notes {
+		// system notes such as "added label" are not comments
+		if note.System {
+			continue
+		}
+		ret = append(ret, platform.Comment{
+			ID:   note.ID,
+			Body: note.Body,
+			User: note.Author.Username,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteComment deletes a note
+func (c *Client) DeleteComment(
+	ctx context.Context,
+	commentID int64,
+) error {
+	return c.do(ctx, http.MethodDelete, c.mergeRequestPath(fmt.Sprintf("notes/%d", commentID)), nil, nil)
+}
+
+// listNotes gets notes including system ones order by posted asc
+func (c *Client) listNotes(ctx context.Context) ([]note, error) {
+	page := 1
+	notes := []note{}
+	for true {
+		var paging []note
+		if err := c.do(
+			ctx,
+			http.MethodGet,
+			fmt.Sprintf("%s?sort=asc&order_by=created_at&page=%d&per_page=%d", c.mergeRequestPath("notes"), page, perPage),
+			nil,
+			&paging,
+		); err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		notes = append(notes, paging...)
+		page++
+		if page > maxPage {
+			return nil, errors.New("Too many comments")
+		}
+	}
+	return notes, nil
+}
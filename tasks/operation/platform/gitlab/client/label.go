@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+	"net/url"
+)
+
+type label struct {
+	Name        string `json:"name,omitempty"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// AddLabel adds label to MR
+func (c *Client) AddLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	return c.addLabel(ctx, c.number, label)
+}
+
+// AddLabelToOtherPR adds label to other MR
+func (c *Client) AddLabelToOtherPR(
+	ctx context.Context,
+	label constant.Label,
+	number int,
+) error {
+	return c.addLabel(ctx, number, label)
+}
+
+// RemoveLabel removes label from MR
+func (c *Client) RemoveLabel(
+	ctx context.Context,
+	label constant.Label,
+) error {
+	return c.do(ctx, http.MethodPut, c.mergeRequestPath(""), map[string]string{
+		"remove_labels": label.Name,
+	}, nil)
+}
+
+func (c *Client) addLabel(ctx context.Context, number int, label constant.Label) error {
+	if err := c.ensureLabel(ctx, label); err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPut, c.projectPath(fmt.Sprintf("merge_requests/%d", number)), map[string]string{
+		"add_labels": label.Name,
+	}, nil)
+}
+
+// ensureLabel creates the project label or updates it if the color or description is different
+func (c *Client) ensureLabel(ctx context.Context, lb constant.Label) error {
+	color := fmt.Sprintf("#%s", lb.Color)
+	var current label
+	err := c.do(ctx, http.MethodGet, c.projectPath(fmt.Sprintf("labels/%s", url.PathEscape(lb.Name))), nil, &current)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return c.do(ctx, http.MethodPost, c.projectPath("labels"), label{
+			Name:        lb.Name,
+			Color:       color,
+			Description: lb.Description,
+		}, nil)
+	}
+	if err != nil {
+		return err
+	}
+	// no need to edit
+	if current.Color == color && current.Description == lb.Description {
+		return nil
+	}
+	return c.do(ctx, http.MethodPut, c.projectPath(fmt.Sprintf("labels/%s", url.PathEscape(lb.Name))), label{
+		Color:       color,
+		Description: lb.Description,
+	}, nil)
+}
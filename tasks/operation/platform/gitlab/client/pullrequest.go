@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+type mergeRequest struct {
+	IID          int      `json:"iid"`
+	TargetBranch string   `json:"target_branch"`
+	SHA          string   `json:"sha"`
+	Labels       []string `json:"labels"`
+	DiffRefs     struct {
+		BaseSHA string `json:"base_sha"`
+	} `json:"diff_refs"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func (mr *mergeRequest) toPullRequest() platform.PullRequest {
+	labels := map[string]constant.Label{}
+	for _, label := range mr.Labels {
+		if lb, ok := constant.NameToLabel[label]; ok {
+			labels[lb.Name] = lb
+		}
+	}
+	return platform.PullRequest{
+		Number:         mr.IID,
+		BaseBranch:     mr.TargetBranch,
+		BaseCommitHash: mr.DiffRefs.BaseSHA,
+		HeadCommitHash: mr.SHA,
+		Labels:         labels,
+		Author:         mr.Author.Username,
+	}
+}
+
+// GetPullRequest gets the MR
+func (c *Client) GetPullRequest(ctx context.Context) (*platform.PullRequest, error) {
+	var mr mergeRequest
+	if err := c.do(ctx, http.MethodGet, c.mergeRequestPath(""), nil, &mr); err != nil {
+		return nil, err
+	}
+	pr := mr.toPullRequest()
+	return &pr, nil
+}
+
+// GetOpenPullRequests gets open MRs
+func (c *Client) GetOpenPullRequests(ctx context.Context) ([]platform.PullRequest, error) {
+	page := 1
+	mrs := []mergeRequest{}
+	for true {
+		var paging []mergeRequest
+		if err := c.do(
+			ctx,
+			http.MethodGet,
+			fmt.Sprintf("%s?state=opened&page=%d&per_page=%d", c.projectPath("merge_requests"), page, perPage),
+			nil,
+			&paging,
+		); err != nil {
+			return nil, err
+		}
+		if len(paging) == 0 {
+			break
+		}
+		mrs = append(mrs, paging...)
+		page++
+		if page > maxPage {
+			return nil, fmt.Errorf("Too many MRs")
+		}
+	}
+	var ret []platform.PullRequest
+	for _, mr := range mrs {
+		ret = append(ret, mr.toPullRequest())
+	}
+	return ret, nil
+}
+
+// MergePullRequest merges MR
+func (c *Client) MergePullRequest(ctx context.Context, message string) error {
+	return c.do(ctx, http.MethodPut, c.mergeRequestPath("merge"), map[string]string{
+		"merge_commit_message": message,
+	}, nil)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform"
+	"net/http"
+)
+
+// approvals is the approval state of the MR
+type approvals struct {
+	ApprovedBy []struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	} `json:"approved_by"`
+}
+
+// ListReviews gets the current approvals of the MR.
+// GitLab doesn't record the commit approved, so they are regarded as approvals on the head commit.
+// Enable resetting approvals when commits are added to the source branch not to count ones on older commits.
+func (c *Client) ListReviews(ctx context.Context) ([]platform.Review, error) {
+	pr, err := c.GetPullRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ap approvals
+	if err := c.do(ctx, http.MethodGet, c.mergeRequestPath("approvals"), nil, &ap); err != nil {
+		return nil, err
+	}
+	ret := []platform.Review{}
+	for _, approver := range ap.ApprovedBy {
+		ret = append(ret, platform.Review{
+			User:       approver.User.Username,
+			Approved:   true,
+			CommitHash: pr.HeadCommitHash,
+		})
+	}
+	return ret, nil
+}
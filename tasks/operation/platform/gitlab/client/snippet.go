@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type snippet struct {
+	WebURL string `json:"web_url"`
+}
+
+// UploadFile creates a private project snippet having the file
+func (c *Client) UploadFile(ctx context.Context, name string, content string) (string, error) {
+	var ret snippet
+	if err := c.do(ctx, http.MethodPost, c.projectPath("snippets"), map[string]string{
+		"title":      fmt.Sprintf("%s of !%d by cdkbot", name, c.number),
+		"file_name":  name,
+		"content":    content,
+		"visibility": "private",
+	}, &ret); err != nil {
+		return "", err
+	}
+	return ret.WebURL, nil
+}
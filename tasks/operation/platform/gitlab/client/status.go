@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/constant"
+	"net/http"
+	"strings"
+)
+
+var stateMap = map[constant.State]string{
+	constant.StateMergeReady:    "success",
+	constant.StateNotMergeReady: "failed",
+	constant.StateRunning:       "running",
+	constant.StateError:         "failed",
+}
+
+var statusName = "cdkbot"
+
+// SetStatus set status of latest commit
+func (c *Client) SetStatus(
+	ctx context.Context,
+	state constant.State,
+	description string,
+) error {
+	pr, err := c.GetPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	// maximum is 255 characters
+	if len(description) > 255 {
+		description = description[:255]
+	}
+
+	err = c.do(ctx, http.MethodPost, c.projectPath(fmt.Sprintf("statuses/%s", pr.HeadCommitHash)), map[string]string{
+		"state":       stateMap[state],
+		"name":        statusName,
+		"description": description,
+	}, nil)
+	// GitLab rejects setting the same state again e.g. running -> running
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest && strings.Contains(apiErr.Body, "Cannot transition status") {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+package gitlab
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/command"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/platform/gitlab/client"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"golang.org/x/xerrors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type project struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	GitHTTPURL        string `json:"git_http_url"`
+}
+
+type user struct {
+	Username string `json:"username"`
+}
+
+type mergeRequestEvent struct {
+	User             user    `json:"user"`
+	Project          project `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		TargetBranch string `json:"target_branch"`
+	} `json:"object_attributes"`
+}
+
+type noteEvent struct {
+	User             user    `json:"user"`
+	Project          project `json:"project"`
+	ObjectAttributes struct {
+		Note         string `json:"note"`
+		NoteableType string `json:"noteable_type"`
+	} `json:"object_attributes"`
+	MergeRequest struct {
+		IID          int    `json:"iid"`
+		TargetBranch string `json:"target_branch"`
+	} `json:"merge_request"`
+}
+
+type pushEvent struct {
+	Ref     string  `json:"ref"`
+	Project project `json:"project"`
+}
+
+// Handler handles GitLab webhook
+func Handler(
+	ctx context.Context,
+	req events.APIGatewayProxyRequest,
+	logger logger.Loggerer,
+) error {
+	hook, err := parse(req)
+	if err != nil {
+		return err
+	}
+
+	switch ev := hook.(type) {
+	case *mergeRequestEvent:
+		cloneURL, err := constructCloneURL(ev.Project.GitHTTPURL)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(
+			client.New(ctx, ev.Project.ID, ev.ObjectAttributes.IID),
+			cloneURL,
+			logger,
+		)
+		switch ev.ObjectAttributes.Action {
+		case "open":
+			if err = runner.Diff(ctx, nil); err == nil {
+				err = runner.DeployPreview(ctx)
+			}
+		case "close", "merge":
+			err = runner.DestroyPreview(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	case *pushEvent:
+		client, err := client.NewWithHeadBranch(
+			ctx,
+			ev.Project.ID,
+			strings.TrimPrefix(ev.Ref, "refs/heads/"),
+		)
+		if err != nil {
+			// When push to branch where MR is not created, nothing is to do
+			return nil
+		}
+		cloneURL, err := constructCloneURL(ev.Project.GitHTTPURL)
+		if err != nil {
+			return err
+		}
+		runner := command.NewRunner(client, cloneURL, logger)
+		if err := runner.Diff(ctx, nil); err != nil {
+			return err
+		}
+		// redeploy the preview with the pushed commit
+		if err := runner.DeployPreview(ctx); err != nil {
+			return err
+		}
+	case *noteEvent:
+		// Only notes on MR are handled
+		if ev.ObjectAttributes.NoteableType != "MergeRequest" {
+			return nil
+		}
+		cloneURL, err := constructCloneURL(ev.Project.GitHTTPURL)
+		if err != nil {
+			return err
+		}
+		if err := command.NewRunner(
+			client.New(ctx, ev.Project.ID, ev.MergeRequest.IID),
+			cloneURL,
+			logger,
+		).Run(ctx, ev.ObjectAttributes.Note, ev.User.Username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MessageGroupID returns the group where webhooks are processed one by one,
+// which is keyed by the project and the target branch of the MR.
+// Empty is returned if nothing is to do for the webhook.
+func MessageGroupID(ctx context.Context, req events.APIGatewayProxyRequest) (string, error) {
+	hook, err := parse(req)
+	if err != nil {
+		return "", err
+	}
+
+	switch ev := hook.(type) {
+	case *mergeRequestEvent:
+		switch ev.ObjectAttributes.Action {
+		case "open", "close", "merge":
+		default:
+			return "", nil
+		}
+		return queue.GroupID(ev.Project.PathWithNamespace, ev.ObjectAttributes.TargetBranch), nil
+	case *pushEvent:
+		client, err := client.NewWithHeadBranch(
+			ctx,
+			ev.Project.ID,
+			strings.TrimPrefix(ev.Ref, "refs/heads/"),
+		)
+		if err != nil {
+			// When push to branch where MR is not created, nothing is to do
+			return "", nil
+		}
+		mr, err := client.GetPullRequest(ctx)
+		if err != nil {
+			return "", err
+		}
+		return queue.GroupID(ev.Project.PathWithNamespace, mr.BaseBranch), nil
+	case *noteEvent:
+		if ev.ObjectAttributes.NoteableType != "MergeRequest" {
+			return "", nil
+		}
+		return queue.GroupID(ev.Project.PathWithNamespace, ev.MergeRequest.TargetBranch), nil
+	}
+	return "", nil
+}
+
+// parse validates the token and parses the webhook
+func parse(req events.APIGatewayProxyRequest) (interface{}, error) {
+	if err := validateToken(
+		req.Headers["X-Gitlab-Token"],
+		os.Getenv("GITLAB_WEBHOOK_SECRET"),
+	); err != nil {
+		return nil, xerrors.Errorf("Token is invalid: %w", err)
+	}
+	hook, err := parseWebHook(req.Headers["X-Gitlab-Event"], []byte(req.Body))
+	if err != nil {
+		return nil, xerrors.Errorf("parse hook error: %w", err)
+	}
+	return hook, nil
+}
+
+func validateToken(token string, secret string) error {
+	if secret == "" {
+		return errors.New("GITLAB_WEBHOOK_SECRET is not set")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return errors.New("token mismatch")
+	}
+	return nil
+}
+
+func parseWebHook(eventType string, payload []byte) (interface{}, error) {
+	var event interface{}
+	switch eventType {
+	case "Merge Request Hook":
+		event = &mergeRequestEvent{}
+	case "Note Hook":
+		event = &noteEvent{}
+	case "Push Hook":
+		event = &pushEvent{}
+	default:
+		return nil, fmt.Errorf("unknown X-Gitlab-Event in message: %v", eventType)
+	}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func constructCloneURL(gitHTTPURL string) (string, error) {
+	u, err := url.Parse(gitHTTPURL)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword("oauth2", os.Getenv("GITLAB_ACCESS_TOKEN"))
+	return u.String(), nil
+}
@@ -127,6 +127,21 @@ func (mr *MockClienterMockRecorder) GetPullRequest(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequest", reflect.TypeOf((*MockClienter)(nil).GetPullRequest), ctx)
 }
 
+// GetUserName mocks base method.
+func (m *MockClienter) GetUserName(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserName", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserName indicates an expected call of GetUserName.
+func (mr *MockClienterMockRecorder) GetUserName(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserName", reflect.TypeOf((*MockClienter)(nil).GetUserName), ctx)
+}
+
 // ListComments mocks base method.
 func (m *MockClienter) ListComments(ctx context.Context) ([]platform.Comment, error) {
 	m.ctrl.T.Helper()
@@ -142,6 +157,21 @@ func (mr *MockClienterMockRecorder) ListComments(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListComments", reflect.TypeOf((*MockClienter)(nil).ListComments), ctx)
 }
 
+// ListReviews mocks base method.
+func (m *MockClienter) ListReviews(ctx context.Context) ([]platform.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReviews", ctx)
+	ret0, _ := ret[0].([]platform.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReviews indicates an expected call of ListReviews.
+func (mr *MockClienterMockRecorder) ListReviews(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReviews", reflect.TypeOf((*MockClienter)(nil).ListReviews), ctx)
+}
+
 // MergePullRequest mocks base method.
 func (m *MockClienter) MergePullRequest(ctx context.Context, message string) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,206 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"sort"
+	"strings"
+)
+
+// Resource changes in DiffCondition
+const (
+	changeAdd     = "add"
+	changeUpdate  = "update"
+	changeRemove  = "remove"
+	changeReplace = "replace"
+)
+
+// Violation is a resource or a change violating the rule
+type Violation struct {
+	Rule        string
+	Description string
+	Stack       string
+	// Empty if the violation is not of a resource such as IAM changes
+	LogicalID    string
+	ResourceType string
+	// Why it violates such as "IAM changes"
+	Reason string
+}
+
+type template struct {
+	Resources map[string]struct {
+		Type       string                 `json:"Type"`
+		Properties map[string]interface{} `json:"Properties"`
+	} `json:"Resources"`
+}
+
+// Evaluate rules applied to the target against templates keyed by the stack and the diffs.
+// Violations are returned in the order of the rules, the stacks and the logical IDs.
+func Evaluate(rules []Rule, target string, templates map[string]string, stackDiffs []cdk.StackDiff) ([]Violation, error) {
+	stacks := []string{}
+	parsed := map[string]template{}
+	for stack, body := range templates {
+		var t template
+		if err := json.Unmarshal([]byte(body), &t); err != nil {
+			return nil, fmt.Errorf("failed to parse the template of %s: %v", stack, err)
+		}
+		stacks = append(stacks, stack)
+		parsed[stack] = t
+	}
+	sort.Strings(stacks)
+	violations := []Violation{}
+	for _, rule := range rules {
+		if !rule.AppliesTo(target) {
+			continue
+		}
+		if rule.Diff != nil {
+			violations = append(violations, rule.evaluateDiff(stackDiffs)...)
+			continue
+		}
+		for _, stack := range stacks {
+			logicalIDs := []string{}
+			for logicalID := range parsed[stack].Resources {
+				logicalIDs = append(logicalIDs, logicalID)
+			}
+			sort.Strings(logicalIDs)
+			for _, logicalID := range logicalIDs {
+				resource := parsed[stack].Resources[logicalID]
+				if !rule.matchesResourceType(resource.Type) {
+					continue
+				}
+				// the path starts from the resource such as "Properties.BucketName"
+				node := map[string]interface{}{"Type": resource.Type, "Properties": resource.Properties}
+				violated := false
+				if rule.Forbid != nil {
+					violated = rule.Forbid.matches(node)
+				} else {
+					violated = !rule.Require.matches(node)
+				}
+				if violated {
+					violations = append(violations, rule.violation(stack, logicalID, resource.Type, ""))
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+func (r *Rule) evaluateDiff(stackDiffs []cdk.StackDiff) []Violation {
+	violations := []Violation{}
+	for _, stackDiff := range stackDiffs {
+		if r.Diff.IAM && (len(stackDiff.IAMStatements) != 0 || len(stackDiff.IAMPolicies) != 0) {
+			violations = append(violations, r.violation(stackDiff.Stack, "", "", "IAM changes"))
+		}
+		if r.Diff.SecurityGroups && len(stackDiff.SecurityGroups) != 0 {
+			violations = append(violations, r.violation(stackDiff.Stack, "", "", "Security group changes"))
+		}
+		for _, change := range stackDiff.Resources {
+			if !r.matchesResourceType(change.ResourceType) {
+				continue
+			}
+			kind := string(change.Type)
+			if change.Type == cdk.ChangeTypeUpdate && change.IsReplacedOrRemoved() {
+				kind = changeReplace
+			}
+			for _, forbidden := range r.Diff.Resources {
+				if forbidden == kind {
+					violations = append(violations, r.violation(stackDiff.Stack, change.LogicalID, change.ResourceType, kind))
+					break
+				}
+			}
+		}
+	}
+	return violations
+}
+
+func (r *Rule) violation(stack, logicalID, resourceType, reason string) Violation {
+	return Violation{
+		Rule:         r.Name,
+		Description:  r.Description,
+		Stack:        stack,
+		LogicalID:    logicalID,
+		ResourceType: resourceType,
+		Reason:       reason,
+	}
+}
+
+// matches returns whether any value at the path matches
+func (c *Condition) matches(resource map[string]interface{}) bool {
+	nodes := []interface{}{resource}
+	if c.Path != "" {
+		for _, key := range strings.Split(c.Path, ".") {
+			next := []interface{}{}
+			for _, node := range nodes {
+				next = append(next, children(node, key)...)
+			}
+			nodes = next
+		}
+	}
+	for _, node := range nodes {
+		if c.matchesObject(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// children returns the value of the key, or all elements if the key is "*"
+func children(node interface{}, key string) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if key == "*" {
+			values := []interface{}{}
+			for _, value := range n {
+				values = append(values, value)
+			}
+			return values
+		}
+		if value, ok := n[key]; ok {
+			return []interface{}{value}
+		}
+	case []interface{}:
+		if key == "*" {
+			return n
+		}
+	}
+	return nil
+}
+
+func (c *Condition) matchesObject(node interface{}) bool {
+	if len(c.Match) == 0 {
+		return true
+	}
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, expected := range c.Match {
+		actual, ok := object[key]
+		if !ok || !matchesValue(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesValue compares values as strings so that 22 in rules matches 22 and "22" in templates
+func matchesValue(actual interface{}, expected interface{}) bool {
+	if values, ok := actual.([]interface{}); ok {
+		for _, value := range values {
+			if matchesValue(value, expected) {
+				return true
+			}
+		}
+		return false
+	}
+	if candidates, ok := expected.([]interface{}); ok {
+		for _, candidate := range candidates {
+			if matchesValue(actual, candidate) {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(actual) == fmt.Sprint(expected)
+}
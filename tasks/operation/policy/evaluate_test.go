@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"github.com/sambaiz/cdkbot/tasks/operation/cdk"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	rules, err := new(Loader).Load("./test_policy")
+	assert.Nil(t, err)
+	rules = append(rules, Rule{
+		Name:          "no-table-deletion",
+		ResourceTypes: []string{"AWS::DynamoDB::*"},
+		Diff:          &DiffCondition{Resources: []string{"remove", "replace"}, IAM: true},
+	})
+	templates := map[string]string{
+		"Stack1": `{
+  "Resources": {
+    "PublicBucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": "public"}},
+    "PrivateBucket": {
+      "Type": "AWS::S3::Bucket",
+      "Properties": {
+        "PublicAccessBlockConfiguration": {"BlockPublicAcls": true, "BlockPublicPolicy": true, "IgnorePublicAcls": true, "RestrictPublicBuckets": true}
+      }
+    },
+    "Sg": {
+      "Type": "AWS::EC2::SecurityGroup",
+      "Properties": {
+        "SecurityGroupIngress": [
+          {"CidrIp": "10.0.0.0/8", "FromPort": 22, "ToPort": 22, "IpProtocol": "tcp"},
+          {"CidrIp": "0.0.0.0/0", "FromPort": 443, "ToPort": 443, "IpProtocol": "tcp"}
+        ]
+      }
+    }
+  }
+}`,
+		"Stack2": `{
+  "Resources": {
+    "OpenSg": {
+      "Type": "AWS::EC2::SecurityGroup",
+      "Properties": {"SecurityGroupIngress": [{"CidrIp": "0.0.0.0/0", "FromPort": "22", "ToPort": "22", "IpProtocol": "tcp"}]}
+    },
+    "Policy": {
+      "Type": "AWS::IAM::Policy",
+      "Properties": {"PolicyDocument": {"Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "*"], "Resource": "*"}]}}
+    }
+  }
+}`,
+	}
+	stackDiffs := []cdk.StackDiff{
+		{
+			Stack: "Stack2",
+			Resources: []cdk.ResourceChange{
+				{Type: cdk.ChangeTypeUpdate, ResourceType: "AWS::DynamoDB::Table", LogicalID: "Table", Impact: cdk.ImpactReplace},
+				{Type: cdk.ChangeTypeUpdate, ResourceType: "AWS::DynamoDB::Table", LogicalID: "Table2"},
+				{Type: cdk.ChangeTypeRemove, ResourceType: "AWS::S3::Bucket", LogicalID: "Bucket", Impact: cdk.ImpactDestroy},
+			},
+			IAMStatements: []cdk.IAMStatementChange{{Type: cdk.ChangeTypeAdd}},
+		},
+	}
+
+	t.Run("develop", func(t *testing.T) {
+		violations, err := Evaluate(rules, "develop", templates, stackDiffs)
+		assert.Nil(t, err)
+		assert.Equal(t, []Violation{
+			{Rule: "no-ssh-from-anywhere", Description: "SSH must not be open to the internet", Stack: "Stack2", LogicalID: "OpenSg", ResourceType: "AWS::EC2::SecurityGroup"},
+			{Rule: "no-public-s3-buckets", Description: "S3 buckets must block public access", Stack: "Stack1", LogicalID: "PublicBucket", ResourceType: "AWS::S3::Bucket"},
+			{Rule: "no-table-deletion", Stack: "Stack2", Reason: "IAM changes"},
+			{Rule: "no-table-deletion", Stack: "Stack2", LogicalID: "Table", ResourceType: "AWS::DynamoDB::Table", Reason: "replace"},
+		}, violations)
+	})
+
+	t.Run("master", func(t *testing.T) {
+		violations, err := Evaluate(rules[2:3], "master", templates, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []Violation{
+			{Rule: "no-iam-wildcard-actions-in-prd", Description: `IAM "*" actions are forbidden in prd`, Stack: "Stack2", LogicalID: "Policy", ResourceType: "AWS::IAM::Policy"},
+		}, violations)
+	})
+
+	t.Run("invalid_template", func(t *testing.T) {
+		_, err := Evaluate(rules, "develop", map[string]string{"Stack1": "{"}, nil)
+		assert.NotNil(t, err)
+	})
+}
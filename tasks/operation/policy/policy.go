@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Loaderer is interface of policy loader
+type Loaderer interface {
+	Load(dir string) ([]Rule, error)
+}
+
+// Loader loads rules from rule files
+type Loader struct{}
+
+// File is a rule file such as "policies/s3.yml"
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a policy evaluated against synthesized templates or the diff.
+// Exactly one of Forbid, Require and Diff is specified.
+type Rule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Targets (base branches) the rule applies to. All targets if empty.
+	Targets []string `yaml:"targets"`
+	// Patterns of resource types such as "AWS::S3::*". All resources if empty.
+	ResourceTypes []string `yaml:"resourceTypes"`
+	// Resources having a value matching the condition violate the rule
+	Forbid *Condition `yaml:"forbid"`
+	// Resources having no values matching the condition violate the rule
+	Require *Condition `yaml:"require"`
+	// Changes in the diff violate the rule
+	Diff *DiffCondition `yaml:"diff"`
+}
+
+// Condition matches values in the resource of the template
+type Condition struct {
+	// Path to the values in the resource such as "Properties.SecurityGroupIngress.*". "*" means all elements.
+	Path string `yaml:"path"`
+	// Keys and expected values of the object at the path. A list of values matches any of them.
+	// If the value in the template is a list, any element is compared. All values match if empty.
+	Match map[string]interface{} `yaml:"match"`
+}
+
+// DiffCondition matches changes in the diff
+type DiffCondition struct {
+	// Changes of resources: "add", "update", "remove" and "replace"
+	Resources []string `yaml:"resources"`
+	// Whether IAM statement and policy changes violate the rule
+	IAM bool `yaml:"iam"`
+	// Whether security group changes violate the rule
+	SecurityGroups bool `yaml:"securityGroups"`
+}
+
+// Load rules of the rule files (*.yml and *.yaml) in the directory in the order of the file names
+func (*Loader) Load(dir string) ([]Rule, error) {
+	paths := []string{}
+	for _, ext := range []string{"*.yml", "*.yaml"} {
+		matched, err := filepath.Glob(filepath.Join(dir, ext))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matched...)
+	}
+	sort.Strings(paths)
+	rules := []Rule{}
+	for _, p := range paths {
+		buf, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var file File
+		if err := yaml.Unmarshal(buf, &file); err != nil {
+			return nil, fmt.Errorf("invalid rule file %s: %v", filepath.Base(p), err)
+		}
+		for _, rule := range file.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule file %s: %v", filepath.Base(p), err)
+			}
+		}
+		rules = append(rules, file.Rules...)
+	}
+	return rules, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is empty")
+	}
+	conditions := 0
+	for _, specified := range []bool{r.Forbid != nil, r.Require != nil, r.Diff != nil} {
+		if specified {
+			conditions++
+		}
+	}
+	if conditions != 1 {
+		return fmt.Errorf("rule %s must have exactly one of forbid, require and diff", r.Name)
+	}
+	for _, pattern := range r.ResourceTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("rule %s has invalid resource type pattern %s", r.Name, pattern)
+		}
+	}
+	if r.Diff != nil {
+		for _, change := range r.Diff.Resources {
+			if change != changeAdd && change != changeUpdate && change != changeRemove && change != changeReplace {
+				return fmt.Errorf("rule %s has unknown resource change %s", r.Name, change)
+			}
+		}
+	}
+	return nil
+}
+
+// AppliesTo returns whether the rule applies to the target
+func (r *Rule) AppliesTo(target string) bool {
+	if len(r.Targets) == 0 {
+		return true
+	}
+	for _, t := range r.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesResourceType returns whether the resource type matches any of resourceTypes patterns
+func (r *Rule) matchesResourceType(resourceType string) bool {
+	if len(r.ResourceTypes) == 0 {
+		return true
+	}
+	for _, pattern := range r.ResourceTypes {
+		if matched, err := path.Match(pattern, resourceType); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
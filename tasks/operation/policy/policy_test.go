@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderLoad(t *testing.T) {
+	tests := []struct {
+		title   string
+		in      string
+		out     []Rule
+		isError bool
+	}{
+		{
+			title: "success",
+			in:    "./test_policy",
+			out: []Rule{
+				{
+					Name:          "no-ssh-from-anywhere",
+					Description:   "SSH must not be open to the internet",
+					ResourceTypes: []string{"AWS::EC2::SecurityGroup"},
+					Forbid: &Condition{
+						Path:  "Properties.SecurityGroupIngress.*",
+						Match: map[string]interface{}{"CidrIp": "0.0.0.0/0", "FromPort": 22},
+					},
+				},
+				{
+					Name:          "no-public-s3-buckets",
+					Description:   "S3 buckets must block public access",
+					ResourceTypes: []string{"AWS::S3::Bucket"},
+					Require: &Condition{
+						Path: "Properties.PublicAccessBlockConfiguration",
+						Match: map[string]interface{}{
+							"BlockPublicAcls":       true,
+							"BlockPublicPolicy":     true,
+							"IgnorePublicAcls":      true,
+							"RestrictPublicBuckets": true,
+						},
+					},
+				},
+				{
+					Name:          "no-iam-wildcard-actions-in-prd",
+					Description:   `IAM "*" actions are forbidden in prd`,
+					Targets:       []string{"master"},
+					ResourceTypes: []string{"AWS::IAM::Policy", "AWS::IAM::ManagedPolicy"},
+					Forbid: &Condition{
+						Path:  "Properties.PolicyDocument.Statement.*",
+						Match: map[string]interface{}{"Effect": "Allow", "Action": "*"},
+					},
+				},
+			},
+		},
+		{
+			title: "no_rule_files",
+			in:    "./notfound",
+			out:   []Rule{},
+		},
+		{
+			title:   "invalid_rule",
+			in:      "./test_policy/invalid",
+			isError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			rules, err := new(Loader).Load(test.in)
+			assert.Equal(t, test.out, rules)
+			assert.Equal(t, test.isError, err != nil)
+		})
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		title   string
+		in      Rule
+		isError bool
+	}{
+		{
+			title: "valid",
+			in:    Rule{Name: "rule", Diff: &DiffCondition{Resources: []string{"remove", "replace"}}},
+		},
+		{
+			title:   "no_name",
+			in:      Rule{Forbid: &Condition{}},
+			isError: true,
+		},
+		{
+			title:   "multiple_conditions",
+			in:      Rule{Name: "rule", Forbid: &Condition{}, Require: &Condition{}},
+			isError: true,
+		},
+		{
+			title:   "invalid_resource_type_pattern",
+			in:      Rule{Name: "rule", ResourceTypes: []string{"AWS::S3::["}, Forbid: &Condition{}},
+			isError: true,
+		},
+		{
+			title:   "unknown_resource_change",
+			in:      Rule{Name: "rule", Diff: &DiffCondition{Resources: []string{"delete"}}},
+			isError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.isError, test.in.validate() != nil)
+		})
+	}
+}
+
+func TestRuleAppliesTo(t *testing.T) {
+	assert.True(t, (&Rule{}).AppliesTo("develop"))
+	assert.True(t, (&Rule{Targets: []string{"master"}}).AppliesTo("master"))
+	assert.False(t, (&Rule{Targets: []string{"master"}}).AppliesTo("develop"))
+}
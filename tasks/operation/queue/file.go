@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileQueue is an in-process implementation of Queue persisted to a local file.
+// Messages are kept in the file until they are deleted,
+// so the ones not processed yet are received again after restart.
+// Messages received more than MaxReceiveCount times are moved to the dead-letter file with suffix ".dead".
+type FileQueue struct {
+	path        string
+	mu          sync.Mutex
+	messages    []Message
+	deadLetters []Message
+	inFlight    map[string]bool
+	// groups having a message in flight
+	inFlightGroups map[string]bool
+	// closed when a message is sent or released
+	arrived chan struct{}
+	seq     int64
+}
+
+// NewMemoryQueue creates FileQueue which is not persisted
+func NewMemoryQueue() *FileQueue {
+	return &FileQueue{
+		messages:       []Message{},
+		deadLetters:    []Message{},
+		inFlight:       map[string]bool{},
+		inFlightGroups: map[string]bool{},
+		arrived:        make(chan struct{}),
+	}
+}
+
+// NewFileQueue loads messages from the file at path if it exists
+func NewFileQueue(path string) (*FileQueue, error) {
+	q := NewMemoryQueue()
+	q.path = path
+	if err := load(path, &q.messages); err != nil {
+		return nil, err
+	}
+	if err := load(q.deadLetterPath(), &q.deadLetters); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func load(path string, messages *[]Message) error {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf, messages); err != nil {
+		return fmt.Errorf("queue file %s is broken: %w", path, err)
+	}
+	return nil
+}
+
+// Send adds a message to the tail
+func (q *FileQueue) Send(ctx context.Context, groupID string, body string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	q.messages = append(q.messages, Message{
+		ID:      fmt.Sprintf("%d-%d", time.Now().UnixNano(), q.seq),
+		GroupID: groupID,
+		Body:    body,
+	})
+	if err := q.save(); err != nil {
+		q.messages = q.messages[:len(q.messages)-1]
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Receive returns the oldest message of the groups having no message in flight.
+// If there is no such message, it waits for a message to be sent or released.
+func (q *FileQueue) Receive(ctx context.Context) (*Message, error) {
+	timer := time.NewTimer(receiveWaitTime)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		msg, err := q.next()
+		arrived := q.arrived
+		q.mu.Unlock()
+		if err != nil || msg != nil {
+			return msg, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, nil
+		case <-arrived:
+		}
+	}
+}
+
+// next puts the oldest receivable message in flight and returns it.
+// A message waiting for retry blocks the following ones in the same group to keep the order.
+func (q *FileQueue) next() (*Message, error) {
+	now := time.Now()
+	waiting := map[string]bool{}
+	for i := range q.messages {
+		msg := &q.messages[i]
+		if q.inFlightGroups[msg.GroupID] || waiting[msg.GroupID] {
+			continue
+		}
+		if msg.VisibleAt.After(now) {
+			waiting[msg.GroupID] = true
+			continue
+		}
+		// saved so that it is counted even if the process stops halfway
+		msg.ReceiveCount++
+		if err := q.save(); err != nil {
+			msg.ReceiveCount--
+			return nil, err
+		}
+		q.inFlight[msg.ID] = true
+		q.inFlightGroups[msg.GroupID] = true
+		received := *msg
+		return &received, nil
+	}
+	return nil, nil
+}
+
+// Extend does nothing because messages are in flight until they are released or the process stops
+func (q *FileQueue) Extend(ctx context.Context, msg *Message) error {
+	return nil
+}
+
+// Delete removes the processed message
+func (q *FileQueue) Delete(ctx context.Context, msg *Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, err := q.index(msg.ID)
+	if err != nil {
+		return err
+	}
+	prev := q.messages
+	q.messages = remove(q.messages, i)
+	if err := q.save(); err != nil {
+		q.messages = prev
+		return err
+	}
+	q.release(prev[i])
+	return nil
+}
+
+// Retry makes the message received again after the delay.
+// If it has been received more than MaxReceiveCount times, it is moved to the dead-letter queue.
+func (q *FileQueue) Retry(ctx context.Context, msg *Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, err := q.index(msg.ID)
+	if err != nil {
+		return err
+	}
+	prev := q.messages
+	failed := prev[i]
+	if failed.ReceiveCount > MaxReceiveCount {
+		deadLetters := append(append([]Message{}, q.deadLetters...), failed)
+		if err := q.write(q.deadLetterPath(), deadLetters); err != nil {
+			return err
+		}
+		q.deadLetters = deadLetters
+		q.messages = remove(q.messages, i)
+	} else {
+		q.messages = append([]Message{}, q.messages...)
+		q.messages[i].VisibleAt = time.Now().Add(RetryDelay(failed.ReceiveCount))
+		q.afterDelay(RetryDelay(failed.ReceiveCount))
+	}
+	if err := q.save(); err != nil {
+		q.messages = prev
+		return err
+	}
+	q.release(failed)
+	return nil
+}
+
+// DeadLetters returns the messages which were given up
+func (q *FileQueue) DeadLetters() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Message{}, q.deadLetters...)
+}
+
+func (q *FileQueue) index(id string) (int, error) {
+	for i, msg := range q.messages {
+		if msg.ID == id {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("message %s is not found", id)
+}
+
+func remove(messages []Message, i int) []Message {
+	return append(append([]Message{}, messages[:i]...), messages[i+1:]...)
+}
+
+// release makes the group of the message receivable
+func (q *FileQueue) release(msg Message) {
+	if q.inFlight[msg.ID] {
+		delete(q.inFlight, msg.ID)
+		delete(q.inFlightGroups, msg.GroupID)
+	}
+	q.notify()
+}
+
+// afterDelay wakes up waiting receivers when the retried message becomes visible
+func (q *FileQueue) afterDelay(delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.notify()
+	})
+}
+
+// notify wakes up waiting receivers
+func (q *FileQueue) notify() {
+	close(q.arrived)
+	q.arrived = make(chan struct{})
+}
+
+func (q *FileQueue) deadLetterPath() string {
+	return q.path + ".dead"
+}
+
+func (q *FileQueue) save() error {
+	return q.write(q.path, q.messages)
+}
+
+// write writes messages to a temporary file and renames it not to leave a broken file
+func (q *FileQueue) write(path string, messages []Message) error {
+	if q.path == "" {
+		return nil
+	}
+	buf, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileQueue(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := NewFileQueue(path)
+	assert.Nil(t, err)
+	assert.Nil(t, q.Send(ctx, "group", "first"))
+	assert.Nil(t, q.Send(ctx, "group", "second"))
+
+	msg, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", msg.Body)
+	assert.Nil(t, q.Delete(ctx, msg))
+	msg, err = q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", msg.Body)
+
+	// messages not deleted are received again after restart
+	restarted, err := NewFileQueue(path)
+	assert.Nil(t, err)
+	msg, err = restarted.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", msg.Body)
+	assert.Nil(t, restarted.Delete(ctx, msg))
+	assert.NotNil(t, restarted.Delete(ctx, msg))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = restarted.Receive(timeoutCtx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestFileQueue_ReceiveBlocksUntilSent(t *testing.T) {
+	ctx := context.Background()
+	q, err := NewFileQueue(filepath.Join(t.TempDir(), "queue.json"))
+	assert.Nil(t, err)
+	received := make(chan *Message)
+	go func() {
+		msg, err := q.Receive(ctx)
+		assert.Nil(t, err)
+		received <- msg
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, q.Send(ctx, "group", "job"))
+	select {
+	case msg := <-received:
+		assert.Equal(t, "job", msg.Body)
+	case <-time.After(time.Second):
+		t.Fatal("message is not received")
+	}
+}
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	var q Queue = NewMemoryQueue()
+	assert.Nil(t, q.Send(ctx, "group", "job"))
+	msg, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "job", msg.Body)
+
+	// in flight message is not received again
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = q.Receive(timeoutCtx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, q.Delete(ctx, msg))
+}
+
+func TestFileQueue_Group(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+	assert.Nil(t, q.Send(ctx, "repo:main", "first"))
+	assert.Nil(t, q.Send(ctx, "repo:main", "second"))
+	assert.Nil(t, q.Send(ctx, "repo:develop", "third"))
+
+	first, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", first.Body)
+	// the group of the first message is in flight
+	third, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "third", third.Body)
+
+	received := make(chan *Message)
+	go func() {
+		msg, err := q.Receive(ctx)
+		assert.Nil(t, err)
+		received <- msg
+	}()
+	select {
+	case <-received:
+		t.Fatal("a message of the group in flight is received")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Nil(t, q.Delete(ctx, first))
+	select {
+	case msg := <-received:
+		assert.Equal(t, "second", msg.Body)
+	case <-time.After(time.Second):
+		t.Fatal("message is not received after the group is released")
+	}
+}
+
+func TestFileQueue_Retry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := NewFileQueue(path)
+	assert.Nil(t, err)
+	assert.Nil(t, q.Send(ctx, "repo:main", "first"))
+	assert.Nil(t, q.Send(ctx, "repo:main", "second"))
+	assert.Nil(t, q.Send(ctx, "repo:develop", "third"))
+
+	first, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, first.ReceiveCount)
+	assert.Nil(t, q.Retry(ctx, first))
+	// the retried message blocks the following ones in the same group until the delay passes
+	msg, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "third", msg.Body)
+	assert.Nil(t, q.Delete(ctx, msg))
+
+	q.mu.Lock()
+	q.messages[0].VisibleAt = time.Time{}
+	q.mu.Unlock()
+	// received once more to be given up after failing MaxReceiveCount times
+	for count := 2; count <= MaxReceiveCount+1; count++ {
+		msg, err = q.Receive(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "first", msg.Body)
+		assert.Equal(t, count, msg.ReceiveCount)
+		assert.Nil(t, q.Retry(ctx, msg))
+		q.mu.Lock()
+		if len(q.messages) != 0 && q.messages[0].Body == "first" {
+			q.messages[0].VisibleAt = time.Time{}
+		}
+		q.mu.Unlock()
+	}
+	// moved to the dead-letter queue after it is given up
+	msg, err = q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", msg.Body)
+
+	restarted, err := NewFileQueue(path)
+	assert.Nil(t, err)
+	deadLetters := restarted.DeadLetters()
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, "first", deadLetters[0].Body)
+	assert.Equal(t, MaxReceiveCount+1, deadLetters[0].ReceiveCount)
+}
+
+func TestGroupID(t *testing.T) {
+	assert.Equal(t, "owner/repo:main", GroupID("owner/repo", "main"))
+	// space is not allowed
+	assert.Len(t, GroupID("owner/repo", "main branch"), 64)
+	assert.NotEqual(t, GroupID("owner/repo", "main branch"), GroupID("owner/repo", "develop branch"))
+}
+
+func TestGivenUp(t *testing.T) {
+	assert.False(t, GivenUp(context.Background()))
+	assert.False(t, GivenUp(WithReceiveCount(context.Background(), MaxReceiveCount)))
+	assert.True(t, GivenUp(WithReceiveCount(context.Background(), MaxReceiveCount+1)))
+}
+
+func TestIsPermanent(t *testing.T) {
+	err := Permanent(errors.New("invalid stack name"))
+	assert.Equal(t, "invalid stack name", err.Error())
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsPermanent(fmt.Errorf("wrapped: %w", err)))
+	assert.False(t, IsPermanent(errors.New("operation error")))
+	assert.False(t, IsPermanent(nil))
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// MaxReceiveCount is how many times a message is processed before it is moved to the dead-letter queue.
+// The message is received once more to clean up the job which is given up such as the running label.
+const MaxReceiveCount = 3
+
+// How long Receive waits for a message.
+// 20 seconds is the maximum of the long polling of SQS.
+const receiveWaitTime = 20 * time.Second
+
+// Message is a job in the queue
+type Message struct {
+	ID string `json:"id"`
+	// Messages in the same group are processed one by one in order
+	GroupID string `json:"group_id"`
+	Body    string `json:"body"`
+	// How many times the message has been received including this time.
+	// More than 1 means the previous run failed or was interrupted.
+	ReceiveCount int `json:"receive_count"`
+	// The message is not received until this time after Retry
+	VisibleAt time.Time `json:"visible_at,omitempty"`
+	// handle to delete the message received from SQS
+	receiptHandle string
+}
+
+// Queue is interface of job queue
+type Queue interface {
+	// Send adds a message to the group
+	Send(ctx context.Context, groupID string, body string) error
+	// Receive returns a message, or nil if no message arrives while waiting.
+	// The message and following ones in the same group are not received while it is processed.
+	Receive(ctx context.Context) (*Message, error)
+	// Extend keeps the message in flight while it is processed for a long time
+	Extend(ctx context.Context, msg *Message) error
+	// Delete removes the processed message
+	Delete(ctx context.Context, msg *Message) error
+	// Retry makes the failed message received again later.
+	// If it has been received more than MaxReceiveCount times, it is moved to the dead-letter queue.
+	Retry(ctx context.Context, msg *Message) error
+}
+
+// RetryDelay returns how long the failed message waits before it is received again.
+// The message which reached MaxReceiveCount is received immediately to be given up.
+func RetryDelay(receiveCount int) time.Duration {
+	if receiveCount >= MaxReceiveCount {
+		return 0
+	}
+	return time.Duration(receiveCount) * time.Minute
+}
+
+type receiveCountKey struct{}
+
+// WithReceiveCount returns the context having the receive count of the message being processed
+func WithReceiveCount(ctx context.Context, receiveCount int) context.Context {
+	return context.WithValue(ctx, receiveCountKey{}, receiveCount)
+}
+
+// Redelivered returns whether the message being processed was received before,
+// which means the previous run failed or was interrupted
+func Redelivered(ctx context.Context) bool {
+	receiveCount, ok := ctx.Value(receiveCountKey{}).(int)
+	return ok && receiveCount > 1
+}
+
+// GivenUp returns whether the message being processed failed MaxReceiveCount times.
+// The job should not be run but cleaned up because the message is moved to the dead-letter queue after this.
+func GivenUp(ctx context.Context) bool {
+	receiveCount, ok := ctx.Value(receiveCountKey{}).(int)
+	return ok && receiveCount > MaxReceiveCount
+}
+
+// permanentError is the error which never succeeds by retrying
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err not to retry the message such as invalid arguments of the command
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns whether err is marked by Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// SQS allows alphanumeric and punctuation characters up to 128 for MessageGroupId
+var validGroupID = regexp.MustCompile(`^[[:graph:]]{1,128}$`)
+
+// GroupID returns the message group ID of the repository and the base branch.
+// If they can't be used as is, the hash is returned.
+func GroupID(repository string, baseBranch string) string {
+	id := fmt.Sprintf("%s:%s", repository, baseBranch)
+	if validGroupID.MatchString(id) {
+		return id
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(id)))
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"strconv"
+	"time"
+)
+
+// A received message is invisible for this long unless Extend is called.
+// If the process stops halfway, it is received again after this.
+const visibilityTimeout = 5 * time.Minute
+
+// SQS is an implementation of Queue with SQS FIFO queue.
+// SQS doesn't return messages of the group while one of them is in flight.
+// The queue should have redrive policy to the dead-letter queue with MaxReceiveCount + 1
+// so that the message is received to be given up before it is moved.
+type SQS struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+}
+
+// NewSQS creates SQS queue
+func NewSQS(client sqsiface.SQSAPI, queueURL string) *SQS {
+	return &SQS{
+		client:   client,
+		queueURL: queueURL,
+	}
+}
+
+// Send sends a message
+func (q *SQS) Send(ctx context.Context, groupID string, body string) error {
+	_, err := q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		MessageBody:    aws.String(body),
+		QueueUrl:       aws.String(q.queueURL),
+		MessageGroupId: aws.String(groupID),
+	})
+	return err
+}
+
+// Receive receives a message with long polling not to call the API continuously while the queue is empty
+func (q *SQS) Receive(ctx context.Context) (*Message, error) {
+	res, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: aws.Int64(1),
+		AttributeNames: aws.StringSlice([]string{
+			sqs.MessageSystemAttributeNameMessageGroupId,
+			sqs.MessageSystemAttributeNameApproximateReceiveCount,
+		}),
+		VisibilityTimeout: aws.Int64(int64(visibilityTimeout.Seconds())),
+		WaitTimeSeconds:   aws.Int64(int64(receiveWaitTime.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Messages) == 0 {
+		return nil, nil
+	}
+	msg := res.Messages[0]
+	receiveCount, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil {
+		receiveCount = 1
+	}
+	return &Message{
+		ID:            aws.StringValue(msg.MessageId),
+		GroupID:       aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]),
+		Body:          aws.StringValue(msg.Body),
+		ReceiveCount:  receiveCount,
+		receiptHandle: aws.StringValue(msg.ReceiptHandle),
+	}, nil
+}
+
+// Extend resets the visibility timeout
+func (q *SQS) Extend(ctx context.Context, msg *Message) error {
+	return q.changeVisibility(ctx, msg, visibilityTimeout)
+}
+
+// Retry makes the message visible after the delay.
+// SQS moves it to the dead-letter queue when it is received more than maxReceiveCount of the redrive policy.
+func (q *SQS) Retry(ctx context.Context, msg *Message) error {
+	return q.changeVisibility(ctx, msg, RetryDelay(msg.ReceiveCount))
+}
+
+func (q *SQS) changeVisibility(ctx context.Context, msg *Message, timeout time.Duration) error {
+	_, err := q.client.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.queueURL),
+		ReceiptHandle:     aws.String(msg.receiptHandle),
+		VisibilityTimeout: aws.Int64(int64(timeout.Seconds())),
+	})
+	return err
+}
+
+// Delete deletes the message
+func (q *SQS) Delete(ctx context.Context, msg *Message) error {
+	_, err := q.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueURL),
+		ReceiptHandle: aws.String(msg.receiptHandle),
+	})
+	return err
+}
@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	sent     []*sqs.SendMessageInput
+	received []*sqs.ReceiveMessageInput
+	messages []*sqs.Message
+	deleted  []string
+	// visibility timeouts changed
+	visibilities []int64
+}
+
+func (f *fakeSQS) SendMessageWithContext(ctx aws.Context, input *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, input)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	f.received = append(f.received, input)
+	messages := f.messages
+	f.messages = nil
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibilityWithContext(ctx aws.Context, input *sqs.ChangeMessageVisibilityInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.visibilities = append(f.visibilities, aws.Int64Value(input.VisibilityTimeout))
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestSQS(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSQS{
+		messages: []*sqs.Message{
+			{
+				MessageId: aws.String("id"),
+				Attributes: map[string]*string{
+					"MessageGroupId":          aws.String("owner/repo:main"),
+					"ApproximateReceiveCount": aws.String("2"),
+				},
+				Body:          aws.String("job"),
+				ReceiptHandle: aws.String("handle"),
+			},
+		},
+	}
+	var q Queue = NewSQS(client, "https://sqs.example.com/queue.fifo")
+
+	assert.Nil(t, q.Send(ctx, "owner/repo:main", "job"))
+	assert.Equal(t, "job", aws.StringValue(client.sent[0].MessageBody))
+	assert.Equal(t, "owner/repo:main", aws.StringValue(client.sent[0].MessageGroupId))
+	assert.Equal(t, "https://sqs.example.com/queue.fifo", aws.StringValue(client.sent[0].QueueUrl))
+
+	msg, err := q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "id", msg.ID)
+	assert.Equal(t, "owner/repo:main", msg.GroupID)
+	assert.Equal(t, "job", msg.Body)
+	assert.Equal(t, 2, msg.ReceiveCount)
+	// long polling
+	assert.Equal(t, int64(20), aws.Int64Value(client.received[0].WaitTimeSeconds))
+	assert.Nil(t, q.Extend(ctx, msg))
+	assert.Nil(t, q.Retry(ctx, msg))
+	msg.ReceiveCount = MaxReceiveCount
+	// made visible immediately to be given up
+	assert.Nil(t, q.Retry(ctx, msg))
+	assert.Equal(t, []int64{300, 120, 0}, client.visibilities)
+	assert.Nil(t, q.Delete(ctx, msg))
+	assert.Equal(t, []string{"handle"}, client.deleted)
+
+	msg, err = q.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, msg)
+}
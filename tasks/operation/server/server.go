@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/handler"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"github.com/sambaiz/cdkbot/tasks/operation/worker"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GitHub limits payloads to 25 MB
+const maxBodySize = 25 << 20
+
+// webhookHeaders are header names looked up by the platform handlers as is.
+// net/http canonicalizes some of them such as X-GitHub-Event to X-Github-Event.
+var webhookHeaders = []string{"X-GitHub-Event", "X-GitHub-Delivery"}
+
+// Server receives webhooks over HTTP and runs them.
+// Jobs of the same repository and base branch are run one by one.
+type Server struct {
+	platform    string
+	queue       queue.Queue
+	concurrency int
+	logger      logger.Loggerer
+	handle      func(ctx context.Context, platform string, req events.APIGatewayProxyRequest, logger logger.Loggerer) error
+	groupID     func(ctx context.Context, platform string, req events.APIGatewayProxyRequest) (string, error)
+}
+
+// New Server
+func New(platform string, queue queue.Queue, concurrency int, logger logger.Loggerer) *Server {
+	return &Server{
+		platform:    platform,
+		queue:       queue,
+		concurrency: concurrency,
+		logger:      logger,
+		handle:      handler.Handle,
+		groupID:     handler.MessageGroupID,
+	}
+}
+
+// Run serves HTTP on addr and works on the queue until ctx is done.
+// A running job is not interrupted and finishes before Run returns.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	workerCtx, cancelWorker := context.WithCancel(ctx)
+	defer cancelWorker()
+	workerDone := make(chan struct{})
+	go func() {
+		if err := s.Work(workerCtx); err != nil {
+			s.logger.Error("worker error", zap.Error(err))
+		}
+		close(workerDone)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+		cancelWorker()
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		s.logger.Error("shutdown server error", zap.Error(err))
+	}
+	<-workerDone
+	return err
+}
+
+// Handler returns the HTTP handler which enqueues webhooks
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.receiveWebhook)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func (s *Server) receiveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := toRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupID, err := s.groupID(r.Context(), s.platform, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if groupID == "" {
+		// nothing is to do
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.queue.Send(r.Context(), groupID, string(payload)); err != nil {
+		s.logger.Error("send message error", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Work receives messages and passes them to the handler until ctx is done
+func (s *Server) Work(ctx context.Context) error {
+	return worker.New(s.queue, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		return s.handle(ctx, s.platform, req, s.logger)
+	}, s.logger).Run(ctx, s.concurrency, false)
+}
+
+// toRequest converts the HTTP request to the form which API Gateway passes to Lambda
+func toRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	if len(body) > maxBodySize {
+		return events.APIGatewayProxyRequest{}, fmt.Errorf("body is larger than %d bytes", maxBodySize)
+	}
+	headers := map[string]string{}
+	for name, values := range r.Header {
+		headers[name] = values[0]
+	}
+	for _, name := range webhookHeaders {
+		if value := r.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	query := map[string]string{}
+	for name, values := range r.URL.Query() {
+		query[name] = values[0]
+	}
+	return events.APIGatewayProxyRequest{
+		Path:                  r.URL.Path,
+		HTTPMethod:            r.Method,
+		Headers:               headers,
+		MultiValueHeaders:     r.Header,
+		QueryStringParameters: query,
+		Body:                  string(body),
+	}, nil
+}
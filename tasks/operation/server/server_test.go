@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := queue.NewFileQueue(path)
+	assert.Nil(t, err)
+	s := New("github", q, 1, logger.MockLogger{})
+	s.groupID = func(ctx context.Context, platform string, req events.APIGatewayProxyRequest) (string, error) {
+		if req.Headers["X-GitHub-Event"] == "ping" {
+			return "", nil
+		}
+		return "sambaiz/cdkbot:master", nil
+	}
+	handled := make(chan events.APIGatewayProxyRequest, 2)
+	s.handle = func(ctx context.Context, platform string, req events.APIGatewayProxyRequest, logger logger.Loggerer) error {
+		assert.Equal(t, "github", platform)
+		handled <- req
+		return nil
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/webhook")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/webhook", strings.NewReader(`{"action":"opened"}`))
+	assert.Nil(t, err)
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature", "sha1=abc")
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// webhooks nothing is to do are not enqueued
+	req, err = http.NewRequest(http.MethodPost, ts.URL+"/webhook", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	req.Header.Set("X-GitHub-Event", "ping")
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Work(ctx)
+		close(done)
+	}()
+	select {
+	case got := <-handled:
+		assert.Equal(t, `{"action":"opened"}`, got.Body)
+		assert.Equal(t, "pull_request", got.Headers["X-GitHub-Event"])
+		assert.Equal(t, "sha1=abc", got.Headers["X-Hub-Signature"])
+	case <-time.After(time.Second):
+		t.Fatal("webhook is not handled")
+	}
+	cancel()
+	<-done
+
+	// handled message is deleted
+	restarted, err := queue.NewFileQueue(path)
+	assert.Nil(t, err)
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+	_, err = restarted.Receive(timeoutCtx)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// How often the message being processed is extended not to be received again
+const heartbeatInterval = time.Minute
+
+// Worker receives webhooks from the queue and passes them to the handler one by one
+type Worker struct {
+	queue             queue.Queue
+	handle            func(ctx context.Context, req events.APIGatewayProxyRequest) error
+	logger            logger.Loggerer
+	heartbeatInterval time.Duration
+	mu                sync.Mutex
+	// when the last retried message becomes visible
+	retryAt time.Time
+}
+
+// New Worker
+func New(
+	queue queue.Queue,
+	handle func(ctx context.Context, req events.APIGatewayProxyRequest) error,
+	logger logger.Loggerer,
+) *Worker {
+	return &Worker{
+		queue:             queue,
+		handle:            handle,
+		logger:            logger,
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// Run processes messages with concurrency goroutines until ctx is done.
+// Messages in the same group are not processed concurrently because the queue doesn't return them.
+// If stopWhenEmpty is true, it returns when no message is received, no job is running
+// and no failed message is waiting for retry.
+// Running jobs are not interrupted by ctx and finish before Run returns.
+func (w *Worker) Run(ctx context.Context, concurrency int, stopWhenEmpty bool) error {
+	var (
+		wg      sync.WaitGroup
+		running int32
+		errOnce sync.Once
+		runErr  error
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				msg, err := w.queue.Receive(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						runErr = err
+						cancel()
+					})
+					return
+				}
+				if msg == nil {
+					if stopWhenEmpty && atomic.LoadInt32(&running) == 0 && !w.waitingRetry() {
+						return
+					}
+					continue
+				}
+				atomic.AddInt32(&running, 1)
+				w.process(msg)
+				atomic.AddInt32(&running, -1)
+			}
+		}()
+	}
+	wg.Wait()
+	return runErr
+}
+
+func (w *Worker) process(msg *queue.Message) {
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal([]byte(msg.Body), &req); err != nil {
+		// never succeeds by retrying
+		w.logger.Error("unmarshal error", zap.Error(err))
+		w.delete(msg)
+		return
+	}
+	stopHeartbeat := w.heartbeat(msg)
+	err := w.handle(queue.WithReceiveCount(context.Background(), msg.ReceiveCount), req)
+	stopHeartbeat()
+	if msg.ReceiveCount > queue.MaxReceiveCount {
+		// The handler only cleaned up the given up job, so it is kept in the dead-letter queue
+		if err != nil {
+			w.logger.Error("give up error", zap.Error(err))
+		}
+		w.retry(msg)
+		return
+	}
+	if queue.IsPermanent(err) {
+		// never succeeds by retrying
+		w.logger.Error("operation error not to retry", zap.Error(err))
+		w.delete(msg)
+		return
+	}
+	if err != nil {
+		w.logger.Error("operation error", zap.Error(err), zap.Int("receiveCount", msg.ReceiveCount))
+		w.retry(msg)
+		return
+	}
+	// The message is deleted after the job so that it is not lost if the process stops halfway
+	w.delete(msg)
+}
+
+// heartbeat extends the message periodically until the returned function is called
+func (w *Worker) heartbeat(msg *queue.Message) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.queue.Extend(context.Background(), msg); err != nil {
+					w.logger.Error("extend message error", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (w *Worker) retry(msg *queue.Message) {
+	if err := w.queue.Retry(context.Background(), msg); err != nil {
+		w.logger.Error("retry message error", zap.Error(err))
+		return
+	}
+	if msg.ReceiveCount > queue.MaxReceiveCount {
+		w.logger.Error("message is moved to the dead-letter queue", zap.String("id", msg.ID))
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if retryAt := time.Now().Add(queue.RetryDelay(msg.ReceiveCount)); retryAt.After(w.retryAt) {
+		w.retryAt = retryAt
+	}
+}
+
+func (w *Worker) waitingRetry() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Now().Before(w.retryAt)
+}
+
+func (w *Worker) delete(msg *queue.Message) {
+	if err := w.queue.Delete(context.Background(), msg); err != nil {
+		w.logger.Error("delete message error", zap.Error(err))
+	}
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sambaiz/cdkbot/tasks/operation/logger"
+	"github.com/sambaiz/cdkbot/tasks/operation/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeQueue returns messages in order and nil after all of them are received
+type fakeQueue struct {
+	messages []*queue.Message
+	mu       sync.Mutex
+	extended int
+	deleted  []string
+	retried  []string
+}
+
+func (q *fakeQueue) Send(ctx context.Context, groupID string, body string) error {
+	q.messages = append(q.messages, &queue.Message{ID: body, GroupID: groupID, Body: body, ReceiveCount: 1})
+	return nil
+}
+
+func (q *fakeQueue) Receive(ctx context.Context) (*queue.Message, error) {
+	if len(q.messages) == 0 {
+		return nil, nil
+	}
+	msg := q.messages[0]
+	q.messages = q.messages[1:]
+	return msg, nil
+}
+
+func (q *fakeQueue) Extend(ctx context.Context, msg *queue.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.extended++
+	return nil
+}
+
+func (q *fakeQueue) Delete(ctx context.Context, msg *queue.Message) error {
+	q.deleted = append(q.deleted, msg.ID)
+	return nil
+}
+
+func (q *fakeQueue) Retry(ctx context.Context, msg *queue.Message) error {
+	q.retried = append(q.retried, msg.ID)
+	return nil
+}
+
+func TestWorker_Run(t *testing.T) {
+	q := &fakeQueue{}
+	for _, body := range []string{"first", "second"} {
+		payload, err := json.Marshal(events.APIGatewayProxyRequest{Body: body})
+		assert.Nil(t, err)
+		assert.Nil(t, q.Send(context.Background(), "group", string(payload)))
+	}
+	// the last attempt not to wait for retry
+	q.messages[0].ReceiveCount = queue.MaxReceiveCount
+	// broken message
+	assert.Nil(t, q.Send(context.Background(), "group", "{"))
+
+	handled := []string{}
+	redelivered := []bool{}
+	err := New(q, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		handled = append(handled, req.Body)
+		redelivered = append(redelivered, queue.Redelivered(ctx))
+		if req.Body == "first" {
+			return errors.New("operation error")
+		}
+		return nil
+	}, logger.MockLogger{}).Run(context.Background(), 1, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second"}, handled)
+	assert.Equal(t, []bool{true, false}, redelivered)
+	// failed message is retried and broken message is deleted
+	assert.Len(t, q.retried, 1)
+	assert.Len(t, q.deleted, 2)
+}
+
+func TestWorker_process_Heartbeat(t *testing.T) {
+	q := &fakeQueue{}
+	payload, err := json.Marshal(events.APIGatewayProxyRequest{Body: "long"})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Send(context.Background(), "group", string(payload)))
+	w := New(q, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, logger.MockLogger{})
+	w.heartbeatInterval = 10 * time.Millisecond
+	w.process(q.messages[0])
+	assert.GreaterOrEqual(t, q.extended, 2)
+	assert.Len(t, q.deleted, 1)
+}
+
+func TestWorker_process_Permanent(t *testing.T) {
+	q := &fakeQueue{}
+	payload, err := json.Marshal(events.APIGatewayProxyRequest{Body: "/deploy Stack;"})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Send(context.Background(), "group", string(payload)))
+	w := New(q, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		return queue.Permanent(errors.New("invalid stack name"))
+	}, logger.MockLogger{})
+	w.process(q.messages[0])
+	// not retried because it never succeeds
+	assert.Len(t, q.retried, 0)
+	assert.Len(t, q.deleted, 1)
+}
+
+func TestWorker_process_GivenUp(t *testing.T) {
+	q := &fakeQueue{}
+	payload, err := json.Marshal(events.APIGatewayProxyRequest{Body: "crashed"})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Send(context.Background(), "group", string(payload)))
+	q.messages[0].ReceiveCount = queue.MaxReceiveCount + 1
+	givenUp := false
+	w := New(q, func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		givenUp = queue.GivenUp(ctx)
+		return nil
+	}, logger.MockLogger{})
+	w.process(q.messages[0])
+	assert.True(t, givenUp)
+	// kept in the dead-letter queue
+	assert.Len(t, q.retried, 1)
+	assert.Len(t, q.deleted, 0)
+	assert.False(t, w.waitingRetry())
+}
+
+func TestWorker_retry(t *testing.T) {
+	q := &fakeQueue{}
+	w := New(q, nil, logger.MockLogger{})
+	w.retry(&queue.Message{ID: "first", ReceiveCount: 1})
+	// waits for the retried message not to stop
+	assert.True(t, w.waitingRetry())
+
+	w = New(q, nil, logger.MockLogger{})
+	w.retry(&queue.Message{ID: "last", ReceiveCount: queue.MaxReceiveCount})
+	// received immediately to be given up
+	assert.False(t, w.waitingRetry())
+	assert.Equal(t, []string{"first", "last"}, q.retried)
+}
+
+func TestWorker_Run_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := New(queue.NewMemoryQueue(), func(ctx context.Context, req events.APIGatewayProxyRequest) error {
+		t.Fatal("nothing should be handled")
+		return nil
+	}, logger.MockLogger{}).Run(ctx, 2, false)
+	assert.Nil(t, err)
+}
+
+func TestWorker_Run_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+	send := func(groupID string, body string) {
+		payload, err := json.Marshal(events.APIGatewayProxyRequest{Body: body})
+		assert.Nil(t, err)
+		assert.Nil(t, q.Send(ctx, groupID, string(payload)))
+	}
+	send("repoA:main", "A1")
+	send("repoA:main", "A2")
+	send("repoB:main", "B1")
+
+	var mu sync.Mutex
+	handled := []string{}
+	b1Done := make(chan struct{})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := New(q, func(_ context.Context, req events.APIGatewayProxyRequest) error {
+		if req.Body == "A1" {
+			// B1 is run while A1 is running
+			<-b1Done
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, req.Body)
+		if req.Body == "B1" {
+			close(b1Done)
+		}
+		if len(handled) == 3 {
+			cancel()
+		}
+		return nil
+	}, logger.MockLogger{}).Run(ctx, 2, false)
+	assert.Nil(t, err)
+	// A2 waits for A1 even though a goroutine is free
+	assert.Equal(t, []string{"B1", "A1", "A2"}, handled)
+}